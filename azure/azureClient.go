@@ -0,0 +1,304 @@
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	auditHttp "iq-scm-audit/http"
+	"iq-scm-audit/scm"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const Provider = "azure"
+const apiVersion = "api-version=6.0"
+const packagingApiVersion = "api-version=6.0-preview.1"
+const repositoriesEndpoint = "/_apis/git/repositories?" + apiVersion
+const projectRepositoriesEndpoint = "/%v/_apis/git/repositories?" + apiVersion
+const buildsEndpoint = "/%v/_apis/build/builds?repositoryId=%v&repositoryType=TfsGit&resultFilter=succeeded&queryOrder=finishTimeDescending&$top=1&" + apiVersion
+const buildArtifactsEndpoint = "/%v/_apis/build/builds/%v/artifacts?" + apiVersion
+const feedPackagesEndpoint = "/%v/_apis/packaging/feeds/%v/packages?packageNameQuery=%v&includeAllVersions=false&" + packagingApiVersion
+const feedPackageContentEndpoint = "/%v/_apis/packaging/feeds/%v/%v/packages/%v/versions/%v/content?" + packagingApiVersion
+const workItemEndpoint = "/%v/_apis/wit/workitems/$%v?" + apiVersion
+const cloudHost = "https://dev.azure.com/"
+
+// IQ Application public ids only allow letters, digits, periods, underscores and hyphens, Azure Repos names also spaces
+var publicIdInvalidCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+type AzureClient struct {
+	OrganizationUrl string
+	Token string
+	WorkItemType string
+	Feed string
+	Limiter auditHttp.Limiter
+}
+
+type Repositories struct {
+	Value []Repository
+}
+
+type Repository struct {
+	Id string
+	Name string
+	WebUrl string
+	SshUrl string
+	Project struct {
+		Name string
+	}
+}
+
+type Builds struct {
+	Value []struct {
+		Id int
+	}
+}
+
+type BuildArtifacts struct {
+	Value []struct {
+		Name string
+		Resource struct {
+			DownloadUrl string
+		}
+	}
+}
+
+type Packages struct {
+	Value []struct {
+		Name string
+		ProtocolType string
+		Versions []struct {
+			Version string
+			IsLatest bool
+		}
+	}
+}
+
+type WorkItem struct {
+	Id int
+	Links struct {
+		Html struct {
+			Href string
+		}
+	} `json:"_links"`
+}
+
+func NewAzureClient(organizationUrl string, token string) *AzureClient {
+	var azureClient = new(AzureClient)
+	azureClient.OrganizationUrl = strings.TrimSuffix(organizationUrl, "/")
+	azureClient.Token = token
+	azureClient.WorkItemType = "Task"
+	return azureClient
+}
+
+func (client *AzureClient) Provider() string {
+	return Provider
+}
+
+func (client *AzureClient) ServerUrl() string {
+	if strings.HasPrefix(client.OrganizationUrl, cloudHost) {
+		return ""
+	}
+	return client.OrganizationUrl
+}
+
+// Queries are "project:<name>" to list every repository in a project and any other text to filter
+// repositories across the organization by name, e.g. "project:Platform billing".
+func (client *AzureClient) GetRepositories(query string) ([]scm.Repository, error) {
+	var project string
+	var nameFilter []string
+	for _, term := range strings.Fields(query) {
+		if strings.HasPrefix(term, "project:") {
+			project = strings.TrimPrefix(term, "project:")
+		} else {
+			nameFilter = append(nameFilter, term)
+		}
+	}
+	name := strings.ToLower(strings.Join(nameFilter, " "))
+
+	endpoint := repositoriesEndpoint
+	if len(project) > 0 {
+		endpoint = fmt.Sprintf(projectRepositoriesEndpoint, url.PathEscape(project))
+	}
+	getBytes, getError := client.getHttpClient().HttpGet(client.OrganizationUrl + endpoint)
+	if getError != nil {
+		return nil, getError
+	}
+	azureRepositories := new(Repositories)
+	unmarshalError := json.Unmarshal(getBytes, &azureRepositories)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
+	}
+
+	var repositories []scm.Repository
+	for _, azureRepository := range azureRepositories.Value {
+		if !strings.Contains(strings.ToLower(azureRepository.Name), name) {
+			continue
+		}
+		log.Println("Getting Azure DevOps Repository - " + azureRepository.Project.Name + "/" + azureRepository.Name)
+		repository := new(scm.Repository)
+		repository.Name = azureRepository.Name
+		repository.PublicId = publicId(azureRepository.Name)
+		repository.NameWithOwner = azureRepository.Project.Name + "/" + azureRepository.Name
+		repository.Url = azureRepository.WebUrl
+		repository.SshUrl = azureRepository.SshUrl
+		releaseAssets, buildError := client.getLatestBuildArtifacts(azureRepository.Project.Name, azureRepository.Id)
+		if buildError != nil {
+			repository.Error = buildError
+		}
+		repository.ReleaseAssets = releaseAssets
+		if len(client.Feed) > 0 {
+			packageFiles, packagesError := client.getLatestFeedPackages(azureRepository.Project.Name, azureRepository.Name)
+			if packagesError != nil && repository.Error == nil {
+				repository.Error = packagesError
+			}
+			repository.PackageFiles = packageFiles
+		}
+		repositories = append(repositories, *repository)
+	}
+	return repositories, nil
+}
+
+// Azure Repos has no issues, a Work Item is created in the repository's project instead.
+func (client *AzureClient) CreateIssue(repositoryNameWithOwner string, title string, markdown string) (string, error) {
+	project := strings.SplitN(repositoryNameWithOwner, "/", 2)[0]
+	postBytes, postError := client.getHttpClient().HttpPostJsonPatch(client.OrganizationUrl + fmt.Sprintf(workItemEndpoint, url.PathEscape(project), url.PathEscape(client.WorkItemType)), []map[string]string {
+		{"op": "add", "path": "/fields/System.Title", "value": title + " - " + repositoryNameWithOwner},
+		{"op": "add", "path": "/fields/System.Description", "value": markdown},
+		{"op": "add", "path": "/multilineFieldsFormat/System.Description", "value": "Markdown"},
+		{"op": "add", "path": "/fields/System.Tags", "value": "Nexus IQ"},
+	})
+	if postError != nil {
+		return "", postError
+	}
+	workItem := new(WorkItem)
+	unmarshalError := json.Unmarshal(postBytes, &workItem)
+	if unmarshalError != nil || workItem.Id == 0 {
+		return "", unexpectedResponse(postBytes)
+	}
+	log.Println(fmt.Sprintf("Created Work Item - %v for %v", workItem.Id, repositoryNameWithOwner))
+	return workItem.Links.Html.Href, nil
+}
+
+func (client *AzureClient) DownloadRelease(assetUrl string) ([]byte, error) {
+	if client.isAzureUrl(assetUrl) {
+		return client.getHttpClient().HttpGet(assetUrl)
+	}
+	httpClient := new(auditHttp.HttpClient)
+	httpClient.Limiter = client.Limiter
+	return httpClient.HttpGet(assetUrl)
+}
+
+func (client *AzureClient) getLatestBuildArtifacts(project string, repositoryId string) ([]scm.Asset, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.OrganizationUrl + fmt.Sprintf(buildsEndpoint, url.PathEscape(project), repositoryId))
+	if getError != nil {
+		return nil, getError
+	}
+	builds := new(Builds)
+	unmarshalError := json.Unmarshal(getBytes, &builds)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
+	}
+	if len(builds.Value) == 0 {
+		return nil, nil
+	}
+
+	getBytes, getError = client.getHttpClient().HttpGet(client.OrganizationUrl + fmt.Sprintf(buildArtifactsEndpoint, url.PathEscape(project), builds.Value[0].Id))
+	if getError != nil {
+		return nil, getError
+	}
+	artifacts := new(BuildArtifacts)
+	unmarshalError = json.Unmarshal(getBytes, &artifacts)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
+	}
+	var assets []scm.Asset
+	for _, artifact := range artifacts.Value {
+		if len(artifact.Resource.DownloadUrl) > 0 {
+			assets = append(assets, scm.Asset{Name: artifact.Name + ".zip", Url: artifact.Resource.DownloadUrl})
+		}
+	}
+	return assets, nil
+}
+
+// Azure Artifacts packages are not linked to repositories, packages in the configured feed named after the
+// repository are evaluated.
+func (client *AzureClient) getLatestFeedPackages(project string, repositoryName string) ([]scm.Asset, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.feedsUrl() + fmt.Sprintf(feedPackagesEndpoint, url.PathEscape(project), url.PathEscape(client.Feed), url.QueryEscape(repositoryName)))
+	// Azure DevOps returns 404 if the feed does not exist in the project
+	if auditHttp.IsStatus(getError, http.StatusNotFound) {
+		return nil, nil
+	}
+	if getError != nil {
+		return nil, getError
+	}
+	packages := new(Packages)
+	unmarshalError := json.Unmarshal(getBytes, &packages)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
+	}
+	var files []scm.Asset
+	for _, pkg := range packages.Value {
+		if !strings.EqualFold(pkg.Name, repositoryName) || len(pkg.Versions) == 0 {
+			continue
+		}
+		protocol := strings.ToLower(pkg.ProtocolType)
+		var extension string
+		switch protocol {
+		case "nuget":
+			extension = ".nupkg"
+		case "npm":
+			extension = ".tgz"
+		default:
+			log.Println("Unsupported Azure Artifacts package type, Skipping - " + pkg.Name + ":" + pkg.ProtocolType)
+			continue
+		}
+		version := pkg.Versions[0].Version
+		files = append(files, scm.Asset{
+			Name: pkg.Name + "-" + version + extension,
+			Url: client.pkgsUrl() + fmt.Sprintf(feedPackageContentEndpoint, url.PathEscape(project), url.PathEscape(client.Feed), protocol, url.PathEscape(pkg.Name), url.PathEscape(version)),
+		})
+	}
+	return files, nil
+}
+
+// Azure DevOps Services serves packaging from separate hosts, Azure DevOps Server serves them from the collection.
+func (client *AzureClient) feedsUrl() string {
+	return strings.Replace(client.OrganizationUrl, cloudHost, "https://feeds.dev.azure.com/", 1)
+}
+
+func (client *AzureClient) pkgsUrl() string {
+	return strings.Replace(client.OrganizationUrl, cloudHost, "https://pkgs.dev.azure.com/", 1)
+}
+
+func (client *AzureClient) isAzureUrl(assetUrl string) bool {
+	for _, baseUrl := range []string{client.OrganizationUrl, client.feedsUrl(), client.pkgsUrl()} {
+		if strings.HasPrefix(assetUrl, baseUrl + "/") {
+			return true
+		}
+	}
+	// Pipeline artifacts of Azure DevOps Services are served from artifact storage hosts
+	parsedUrl, parseError := url.Parse(assetUrl)
+	return parseError == nil && parsedUrl.Scheme == "https" && strings.HasSuffix(parsedUrl.Host, ".visualstudio.com")
+}
+
+func (client *AzureClient) getHttpClient() *auditHttp.HttpClient {
+	// Personal Access Tokens are sent as the password of basic authentication with any username
+	httpClient := new(auditHttp.HttpClient)
+	httpClient.Username = "iq-scm-audit"
+	httpClient.Password = client.Token
+	httpClient.Limiter = client.Limiter
+	return httpClient
+}
+
+func unexpectedResponse(responseBytes []byte) error {
+	return errors.New("unexpected response from Azure DevOps - " + string(responseBytes))
+}
+
+// publicId replaces each run of characters IQ does not allow in a public id with a hyphen, e.g. "My Repo (v2)" becomes
+// "My-Repo-v2".
+func publicId(name string) string {
+	return strings.Trim(publicIdInvalidCharacters.ReplaceAllString(name, "-"), "-")
+}
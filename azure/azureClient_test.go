@@ -0,0 +1,20 @@
+package azure
+
+import "testing"
+
+func TestPublicId(t *testing.T) {
+	tests := []struct {
+		name string
+		publicId string
+	}{
+		{"payments-api", "payments-api"},
+		{"My Repo (v2)", "My-Repo-v2"},
+		{"web.ui_next", "web.ui_next"},
+		{"  Ops & Tools  ", "Ops-Tools"},
+	}
+	for _, test := range tests {
+		if publicId := publicId(test.name); publicId != test.publicId {
+			t.Errorf("publicId(%q) = %q, want %q", test.name, publicId, test.publicId)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	auditHttp "iq-scm-audit/http"
+	"iq-scm-audit/scm"
+	"log"
+	"net/url"
+	"strings"
+)
+
+const Provider = "bitbucket"
+const apiEndpoint = "/rest/api/1.0"
+const repositoriesEndpoint = apiEndpoint + "/repos"
+const projectRepositoriesEndpoint = apiEndpoint + "/projects/%v/repos"
+const repositoryEndpoint = projectRepositoriesEndpoint + "/%v"
+const tagsEndpoint = repositoryEndpoint + "/tags?orderBy=MODIFICATION&limit=1"
+const archiveEndpoint = repositoryEndpoint + "/archive?format=zip&at=%v"
+const pullRequestsEndpoint = repositoryEndpoint + "/pull-requests?state=OPEN&order=NEWEST&limit=1"
+const pullRequestCommentsEndpoint = repositoryEndpoint + "/pull-requests/%v/comments"
+const jiraIssueEndpoint = "/rest/api/2/issue"
+const jiraSearchEndpoint = "/rest/api/2/search?jql=%v&fields=summary&startAt=%v&maxResults=100"
+const pullRequestPage = "/projects/%v/repos/%v/pull-requests/%v"
+const pageSize = 100
+
+// Characters with a meaning in Jira text searches, the summary is matched exactly once found
+var jiraTextSearchReplacer = strings.NewReplacer("\\", " ", "\"", " ", "+", " ", "-", " ", "&", " ", "|", " ", "!", " ",
+	"(", " ", ")", " ", "{", " ", "}", " ", "[", " ", "]", " ", "^", " ", "~", " ", "*", " ", "?", " ", ":", " ", "/", " ")
+
+type BitbucketClient struct {
+	BaseUrl string
+	Token string
+	JiraUrl string
+	JiraToken string
+	JiraProject string
+	Limiter auditHttp.Limiter
+}
+
+type page struct {
+	IsLastPage bool
+	NextPageStart int
+	Values json.RawMessage
+}
+
+type Repository struct {
+	Slug string
+	Name string
+	Project struct {
+		Key string
+	}
+	Links struct {
+		Clone []struct {
+			Href string
+			Name string
+		}
+		Self []struct {
+			Href string
+		}
+	}
+}
+
+type Tag struct {
+	Id string
+	DisplayId string
+}
+
+type PullRequest struct {
+	Id int
+	Links struct {
+		Self []struct {
+			Href string
+		}
+	}
+}
+
+type JiraIssue struct {
+	Key string
+	Fields struct {
+		Summary string
+	}
+}
+
+type JiraSearch struct {
+	Total int
+	Issues []JiraIssue
+}
+
+func NewBitbucketClient(baseUrl string, token string) *BitbucketClient {
+	var bitbucketClient = new(BitbucketClient)
+	bitbucketClient.BaseUrl = strings.TrimSuffix(baseUrl, "/")
+	bitbucketClient.Token = token
+	return bitbucketClient
+}
+
+func (client *BitbucketClient) Provider() string {
+	return Provider
+}
+
+func (client *BitbucketClient) ServerUrl() string {
+	return client.BaseUrl
+}
+
+// Queries are "project:<key>" to list every repository in a project and any other text to filter
+// repositories by name, e.g. "project:PLAT billing".
+func (client *BitbucketClient) GetRepositories(query string) ([]scm.Repository, error) {
+	var projectKey string
+	var nameFilter []string
+	for _, term := range strings.Fields(query) {
+		if strings.HasPrefix(term, "project:") {
+			projectKey = strings.TrimPrefix(term, "project:")
+		} else {
+			nameFilter = append(nameFilter, term)
+		}
+	}
+	name := strings.Join(nameFilter, " ")
+
+	var bitbucketRepositories []Repository
+	endpoint := repositoriesEndpoint + "?name=" + url.QueryEscape(name)
+	if len(projectKey) > 0 {
+		endpoint = fmt.Sprintf(projectRepositoriesEndpoint, url.PathEscape(projectKey)) + "?"
+	}
+	listError := client.getAll(endpoint, func(valueBytes []byte) error {
+		var values []Repository
+		valueError := json.Unmarshal(valueBytes, &values)
+		if valueError != nil {
+			return unexpectedResponse(valueBytes)
+		}
+		for _, repository := range values {
+			if len(projectKey) > 0 && !strings.Contains(strings.ToLower(repository.Name), strings.ToLower(name)) {
+				continue
+			}
+			bitbucketRepositories = append(bitbucketRepositories, repository)
+		}
+		return nil
+	})
+	if listError != nil {
+		return nil, listError
+	}
+
+	var repositories []scm.Repository
+	for _, bitbucketRepository := range bitbucketRepositories {
+		log.Println("Getting Bitbucket Repository - " + bitbucketRepository.Project.Key + "/" + bitbucketRepository.Slug)
+		repository := new(scm.Repository)
+		repository.Name = bitbucketRepository.Slug
+		repository.NameWithOwner = bitbucketRepository.Project.Key + "/" + bitbucketRepository.Slug
+		for _, clone := range bitbucketRepository.Links.Clone {
+			switch clone.Name {
+			case "http":
+				repository.Url = clone.Href
+			case "ssh":
+				repository.SshUrl = clone.Href
+			}
+		}
+		releaseAssets, tagError := client.getLatestTagAssets(bitbucketRepository.Project.Key, bitbucketRepository.Slug)
+		if tagError != nil {
+			repository.Error = tagError
+		}
+		repository.ReleaseAssets = releaseAssets
+		repositories = append(repositories, *repository)
+	}
+	return repositories, nil
+}
+
+// Bitbucket Server has no issues, so the note is filed in Jira when configured and otherwise left as a
+// comment on the newest open pull request.
+func (client *BitbucketClient) CreateIssue(repositoryNameWithOwner string, title string, markdown string) (string, error) {
+	projectKey, slug := splitNameWithOwner(repositoryNameWithOwner)
+	if len(client.JiraUrl) > 0 && len(client.JiraProject) > 0 {
+		// Looked up first, an issue filed by an audit that failed to record it is updated instead of filed twice
+		summary := title + " - " + repositoryNameWithOwner
+		jiraIssue, findError := client.findJiraIssue(summary)
+		if findError != nil {
+			return "", findError
+		}
+		if jiraIssue != nil {
+			updateError := client.updateJiraIssue(jiraIssue.Key, markdown)
+			if updateError != nil {
+				return "", updateError
+			}
+			log.Println("Updated existing Jira Issue - " + jiraIssue.Key + " for " + repositoryNameWithOwner)
+		} else {
+			var jiraError error
+			jiraIssue, jiraError = client.createJiraIssue(summary, markdown)
+			if jiraError != nil {
+				return "", jiraError
+			}
+			log.Println("Created Jira Issue - " + jiraIssue.Key + " for " + repositoryNameWithOwner)
+		}
+		return strings.TrimSuffix(client.JiraUrl, "/") + "/browse/" + jiraIssue.Key, nil
+	}
+
+	getBytes, getError := client.getHttpClient().HttpGet(client.BaseUrl + fmt.Sprintf(pullRequestsEndpoint, url.PathEscape(projectKey), url.PathEscape(slug)))
+	if getError != nil {
+		return "", getError
+	}
+	pullRequests := new(page)
+	unmarshalError := json.Unmarshal(getBytes, &pullRequests)
+	if unmarshalError != nil {
+		return "", unexpectedResponse(getBytes)
+	}
+	var values []PullRequest
+	_ = json.Unmarshal(pullRequests.Values, &values)
+	if len(values) == 0 {
+		log.Println("No open pull request to comment on and Jira is not configured, Skipping - " + repositoryNameWithOwner)
+		return "", scm.ErrIssueSkipped
+	}
+	_, postError := client.getHttpClient().HttpPost(client.BaseUrl + fmt.Sprintf(pullRequestCommentsEndpoint, url.PathEscape(projectKey), url.PathEscape(slug), values[0].Id), map[string]string {
+		"text": "## " + title + "\n\n" + markdown,
+	})
+	if postError != nil {
+		return "", postError
+	}
+	if len(values[0].Links.Self) == 0 {
+		return client.BaseUrl + fmt.Sprintf(pullRequestPage, url.PathEscape(projectKey), url.PathEscape(slug), values[0].Id), nil
+	}
+	return values[0].Links.Self[0].Href, nil
+}
+
+func (client *BitbucketClient) DownloadRelease(assetUrl string) ([]byte, error) {
+	if strings.HasPrefix(assetUrl, client.BaseUrl + "/") {
+		return client.getHttpClient().HttpGet(assetUrl)
+	}
+	httpClient := new(auditHttp.HttpClient)
+	httpClient.Limiter = client.Limiter
+	return httpClient.HttpGet(assetUrl)
+}
+
+func (client *BitbucketClient) getLatestTagAssets(projectKey string, slug string) ([]scm.Asset, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.BaseUrl + fmt.Sprintf(tagsEndpoint, url.PathEscape(projectKey), url.PathEscape(slug)))
+	if getError != nil {
+		return nil, getError
+	}
+	tags := new(page)
+	unmarshalError := json.Unmarshal(getBytes, &tags)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
+	}
+	var values []Tag
+	_ = json.Unmarshal(tags.Values, &values)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	tag := values[0]
+	// Bitbucket Server has no API to list attachments, the source archive of the latest tag is evaluated instead
+	return []scm.Asset{{
+		Name: slug + "-" + strings.Replace(tag.DisplayId, "/", "-", -1) + ".zip",
+		Url: client.BaseUrl + fmt.Sprintf(archiveEndpoint, url.PathEscape(projectKey), url.PathEscape(slug), url.QueryEscape(tag.Id)),
+	}}, nil
+}
+
+// findJiraIssue returns the unresolved issue of the Jira project with exactly the summary, or nil when there is none.
+func (client *BitbucketClient) findJiraIssue(summary string) (*JiraIssue, error) {
+	jql := fmt.Sprintf(`project = "%v" AND summary ~ "\"%v\"" AND statusCategory != Done`, client.JiraProject, jiraTextSearchReplacer.Replace(summary))
+	startAt := 0
+	for {
+		getBytes, getError := client.getJiraHttpClient().HttpGet(strings.TrimSuffix(client.JiraUrl, "/") + fmt.Sprintf(jiraSearchEndpoint, url.QueryEscape(jql), startAt))
+		if getError != nil {
+			return nil, getError
+		}
+		search := new(JiraSearch)
+		unmarshalError := json.Unmarshal(getBytes, &search)
+		if unmarshalError != nil {
+			return nil, errors.New("unexpected response from Jira - " + string(getBytes))
+		}
+		for _, issue := range search.Issues {
+			if issue.Fields.Summary == summary {
+				return &issue, nil
+			}
+		}
+		startAt += len(search.Issues)
+		if len(search.Issues) == 0 || startAt >= search.Total {
+			return nil, nil
+		}
+	}
+}
+
+func (client *BitbucketClient) updateJiraIssue(key string, markdown string) error {
+	_, putError := client.getJiraHttpClient().HttpPut(strings.TrimSuffix(client.JiraUrl, "/") + jiraIssueEndpoint + "/" + url.PathEscape(key), map[string]interface{} {
+		"fields": map[string]string {
+			"description": markdown,
+		},
+	})
+	return putError
+}
+
+func (client *BitbucketClient) createJiraIssue(summary string, markdown string) (*JiraIssue, error) {
+	postBytes, postError := client.getJiraHttpClient().HttpPost(strings.TrimSuffix(client.JiraUrl, "/") + jiraIssueEndpoint, map[string]interface{} {
+		"fields": map[string]interface{} {
+			"project": map[string]string {
+				"key": client.JiraProject,
+			},
+			"issuetype": map[string]string {
+				"name": "Task",
+			},
+			"summary": summary,
+			"description": markdown,
+		},
+	})
+	if postError != nil {
+		return nil, postError
+	}
+	jiraIssue := new(JiraIssue)
+	unmarshalError := json.Unmarshal(postBytes, &jiraIssue)
+	if unmarshalError != nil || len(jiraIssue.Key) == 0 {
+		return nil, errors.New("unexpected response from Jira - " + string(postBytes))
+	}
+	return jiraIssue, nil
+}
+
+func (client *BitbucketClient) getAll(endpoint string, appendValues func(valueBytes []byte) error) error {
+	start := 0
+	for {
+		pageUrl := client.BaseUrl + endpoint + fmt.Sprintf("&limit=%v&start=%v", pageSize, start)
+		getBytes, getError := client.getHttpClient().HttpGet(pageUrl)
+		if getError != nil {
+			return getError
+		}
+		repositoryPage := new(page)
+		unmarshalError := json.Unmarshal(getBytes, &repositoryPage)
+		if unmarshalError != nil {
+			return unexpectedResponse(getBytes)
+		}
+		appendError := appendValues(repositoryPage.Values)
+		if appendError != nil {
+			return appendError
+		}
+		if repositoryPage.IsLastPage {
+			return nil
+		}
+		start = repositoryPage.NextPageStart
+	}
+}
+
+func unexpectedResponse(responseBytes []byte) error {
+	return errors.New("unexpected response from Bitbucket - " + string(responseBytes))
+}
+
+func (client *BitbucketClient) getHttpClient() *auditHttp.HttpClient {
+	httpClient := new(auditHttp.HttpClient)
+	httpClient.Token = client.Token
+	httpClient.Limiter = client.Limiter
+	return httpClient
+}
+
+func (client *BitbucketClient) getJiraHttpClient() *auditHttp.HttpClient {
+	jiraHttpClient := new(auditHttp.HttpClient)
+	jiraHttpClient.Token = client.JiraToken
+	jiraHttpClient.Limiter = client.Limiter
+	return jiraHttpClient
+}
+
+func splitNameWithOwner(repositoryNameWithOwner string) (string, string) {
+	parts := strings.SplitN(repositoryNameWithOwner, "/", 2)
+	if len(parts) < 2 {
+		return "", parts[0]
+	}
+	return parts[0], parts[1]
+}
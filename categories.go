@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"iq-scm-audit/iq"
+	"iq-scm-audit/scm"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	CategoryAttributeTopic = "topic"
+	CategoryAttributeLanguage = "language"
+	CategoryAttributeVisibility = "visibility"
+	CategoryAttributeArchived = "archived"
+)
+
+// CategoryRule applies an IQ Application Category to the applications of repositories with an attribute value.
+type CategoryRule struct {
+	Attribute string
+	Value string
+	Category string
+}
+
+// CategoryChanges are the IQ Application Categories an application gains and loses to match the rules.
+type CategoryChanges struct {
+	TagIds []string
+	Added []string
+	Removed []string
+}
+
+// parseCategoryRules reads comma separated attribute:value=Category rules, e.g. visibility:public=Distributed.
+func parseCategoryRules(rules string) ([]CategoryRule, error) {
+	var categoryRules []CategoryRule
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if len(rule) == 0 {
+			continue
+		}
+		condition := strings.SplitN(rule, "=", 2)
+		attributeValue := strings.SplitN(condition[0], ":", 2)
+		if len(condition) != 2 || len(attributeValue) != 2 || len(strings.TrimSpace(condition[1])) == 0 {
+			return nil, errors.New("IQ category rule is not attribute:value=Category - " + rule)
+		}
+		categoryRule := CategoryRule{
+			Attribute: strings.ToLower(strings.TrimSpace(attributeValue[0])),
+			Value: strings.TrimSpace(attributeValue[1]),
+			Category: strings.TrimSpace(condition[1]),
+		}
+		switch categoryRule.Attribute {
+		case CategoryAttributeTopic, CategoryAttributeLanguage, CategoryAttributeVisibility, CategoryAttributeArchived:
+		default:
+			return nil, errors.New("unsupported IQ category rule attribute, use topic, language, visibility or archived - " + rule)
+		}
+		categoryRules = append(categoryRules, categoryRule)
+	}
+	return categoryRules, nil
+}
+
+func (rule CategoryRule) Matches(repository scm.Repository) bool {
+	switch rule.Attribute {
+	case CategoryAttributeTopic:
+		for _, topic := range repository.Topics {
+			if strings.EqualFold(topic, rule.Value) {
+				return true
+			}
+		}
+	case CategoryAttributeLanguage:
+		return strings.EqualFold(repository.Language, rule.Value)
+	case CategoryAttributeVisibility:
+		return strings.EqualFold(repository.Visibility, rule.Value)
+	case CategoryAttributeArchived:
+		return strings.EqualFold(strconv.FormatBool(repository.Archived), rule.Value)
+	}
+	return false
+}
+
+// matchingCategories returns the categories the rules apply to the repository, and every category named by a rule.
+// Only the categories named by a rule are reconciled, any others applied in IQ are kept.
+func matchingCategories(rules []CategoryRule, repository scm.Repository) (map[string]bool, map[string]bool) {
+	matching := make(map[string]bool)
+	managed := make(map[string]bool)
+	for _, rule := range rules {
+		category := strings.ToLower(rule.Category)
+		managed[category] = true
+		if rule.Matches(repository) {
+			matching[category] = true
+		}
+	}
+	return matching, managed
+}
+
+// ruleCategories returns the names of the categories the rules apply to the repository.
+func ruleCategories(rules []CategoryRule, repository scm.Repository) []string {
+	var names []string
+	found := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Matches(repository) && !found[strings.ToLower(rule.Category)] {
+			found[strings.ToLower(rule.Category)] = true
+			names = append(names, rule.Category)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// categoryChanges compares the categories applied to an application with those the rules apply.
+func categoryChanges(rules []CategoryRule, repository scm.Repository, categories []iq.ApplicationCategory, currentTagIds []string) CategoryChanges {
+	matching, managed := matchingCategories(rules, repository)
+	applied := make(map[string]bool)
+	for _, tagId := range currentTagIds {
+		applied[tagId] = true
+	}
+
+	var changes CategoryChanges
+	found := make(map[string]bool)
+	available := make(map[string]bool)
+	for _, category := range categories {
+		name := strings.ToLower(category.Name)
+		found[name] = true
+		available[category.Id] = true
+		switch {
+		case !managed[name]:
+			if applied[category.Id] {
+				changes.TagIds = append(changes.TagIds, category.Id)
+			}
+		case matching[name]:
+			changes.TagIds = append(changes.TagIds, category.Id)
+			if !applied[category.Id] {
+				changes.Added = append(changes.Added, category.Name)
+			}
+		case applied[category.Id]:
+			changes.Removed = append(changes.Removed, category.Name)
+		}
+	}
+	for _, tagId := range currentTagIds {
+		// Categories that no longer apply to the organization are left to IQ
+		if !available[tagId] {
+			changes.TagIds = append(changes.TagIds, tagId)
+		}
+	}
+	for name := range matching {
+		if !found[name] {
+			log.Println("No IQ Application Category " + name + " available, Skipping - " + repository.NameWithOwner)
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	return changes
+}
+
+// reconcileCategories applies the categories of the matching rules to an application and removes those of the rules that
+// no longer match.
+func reconcileCategories(iqClient *iq.IqClient, rules []CategoryRule, repository scm.Repository, applicationId string, publicId string) error {
+	categories, categoriesError := iqClient.GetApplicationCategories(publicId)
+	if categoriesError != nil {
+		return categoriesError
+	}
+	tagIds, tagsError := iqClient.GetApplicationTagIds(applicationId)
+	if tagsError != nil {
+		return tagsError
+	}
+	changes := categoryChanges(rules, repository, categories, tagIds)
+	if len(changes.Added) == 0 && len(changes.Removed) == 0 {
+		return nil
+	}
+	log.Println("Updating IQ Application Categories, adding [" + strings.Join(changes.Added, ", ") + "] removing [" +
+		strings.Join(changes.Removed, ", ") + "] - " + publicId)
+	return iqClient.SetApplicationTagIds(applicationId, changes.TagIds)
+}
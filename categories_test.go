@@ -0,0 +1,103 @@
+package main
+
+import (
+	"iq-scm-audit/iq"
+	"iq-scm-audit/scm"
+	"strings"
+	"testing"
+)
+
+func TestParseCategoryRules(t *testing.T) {
+	tests := []struct {
+		rules string
+		parsed []CategoryRule
+		valid bool
+	}{
+		{"", nil, true},
+		{"visibility:public=Distributed", []CategoryRule{{CategoryAttributeVisibility, "public", "Distributed"}}, true},
+		{" Topic:payments = PCI , language:Go=Backend", []CategoryRule{{CategoryAttributeTopic, "payments", "PCI"}, {CategoryAttributeLanguage, "Go", "Backend"}}, true},
+		{"archived:true=Retired,", []CategoryRule{{CategoryAttributeArchived, "true", "Retired"}}, true},
+		{"visibility=Distributed", nil, false},
+		{"visibility:public=", nil, false},
+		{"owner:platform=Internal", nil, false},
+	}
+	for _, test := range tests {
+		rules, parseError := parseCategoryRules(test.rules)
+		if (parseError == nil) != test.valid {
+			t.Errorf("parseCategoryRules(%q) returned %v, want valid %v", test.rules, parseError, test.valid)
+			continue
+		}
+		if len(rules) != len(test.parsed) {
+			t.Errorf("parseCategoryRules(%q) = %v, want %v", test.rules, rules, test.parsed)
+			continue
+		}
+		for index := range rules {
+			if rules[index] != test.parsed[index] {
+				t.Errorf("parseCategoryRules(%q) = %v, want %v", test.rules, rules, test.parsed)
+			}
+		}
+	}
+}
+
+func TestCategoryRuleMatches(t *testing.T) {
+	repository := scm.Repository{Topics: []string{"Payments", "api"}, Language: "Go", Visibility: "public", Archived: true}
+	tests := []struct {
+		rule CategoryRule
+		matches bool
+	}{
+		{CategoryRule{CategoryAttributeTopic, "payments", "PCI"}, true},
+		{CategoryRule{CategoryAttributeTopic, "web", "Frontend"}, false},
+		{CategoryRule{CategoryAttributeLanguage, "go", "Backend"}, true},
+		{CategoryRule{CategoryAttributeVisibility, "private", "Internal"}, false},
+		{CategoryRule{CategoryAttributeArchived, "true", "Retired"}, true},
+		{CategoryRule{CategoryAttributeArchived, "false", "Active"}, false},
+	}
+	for _, test := range tests {
+		if matches := test.rule.Matches(repository); matches != test.matches {
+			t.Errorf("%v.Matches() = %v, want %v", test.rule, matches, test.matches)
+		}
+	}
+}
+
+func TestCategoryChanges(t *testing.T) {
+	rules := []CategoryRule{
+		{CategoryAttributeVisibility, "public", "Distributed"},
+		{CategoryAttributeTopic, "payments", "PCI"},
+		{CategoryAttributeArchived, "true", "Retired"},
+		{CategoryAttributeLanguage, "Rust", "Systems"},
+	}
+	categories := []iq.ApplicationCategory{{Id: "distributed", Name: "Distributed"}, {Id: "pci", Name: "PCI"}, {Id: "retired", Name: "Retired"}, {Id: "critical", Name: "Critical"}}
+	tests := []struct {
+		name string
+		repository scm.Repository
+		currentTagIds []string
+		tagIds string
+		added string
+		removed string
+	}{
+		{"new application", scm.Repository{Visibility: "public", Topics: []string{"payments"}}, nil, "distributed,pci", "Distributed,PCI", ""},
+		{"unchanged", scm.Repository{Visibility: "public"}, []string{"distributed"}, "distributed", "", ""},
+		{"rule no longer matches", scm.Repository{Visibility: "private"}, []string{"distributed", "pci"}, "", "", "Distributed,PCI"},
+		{"unmanaged category kept", scm.Repository{Archived: true}, []string{"critical"}, "retired,critical", "Retired", ""},
+		{"category of another organization kept", scm.Repository{}, []string{"elsewhere"}, "elsewhere", "", ""},
+		{"missing category skipped", scm.Repository{Language: "Rust"}, nil, "", "", ""},
+	}
+	for _, test := range tests {
+		changes := categoryChanges(rules, test.repository, categories, test.currentTagIds)
+		if strings.Join(changes.TagIds, ",") != test.tagIds || strings.Join(changes.Added, ",") != test.added || strings.Join(changes.Removed, ",") != test.removed {
+			t.Errorf("categoryChanges(%v) = %v %v %v, want %v %v %v", test.name, changes.TagIds, changes.Added, changes.Removed, test.tagIds, test.added, test.removed)
+		}
+	}
+}
+
+func TestRuleCategories(t *testing.T) {
+	rules := []CategoryRule{
+		{CategoryAttributeVisibility, "public", "Distributed"},
+		{CategoryAttributeTopic, "payments", "PCI"},
+		{CategoryAttributeTopic, "cards", "pci"},
+	}
+	categories := ruleCategories(rules, scm.Repository{Visibility: "public", Topics: []string{"payments", "cards"}})
+	if strings.Join(categories, ",") != "Distributed,PCI" {
+		t.Errorf("ruleCategories() = %v, want Distributed,PCI", categories)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AuditTarget is one audit of a configuration file, its values are named after the command line options.
+type AuditTarget struct {
+	Name string
+	Values map[string]string
+}
+
+// loadConfigFile reads the shared settings and the targets of a YAML or JSON configuration file, e.g.
+//
+//   iqServerUrl: https://iq.example.com
+//   targets:
+//     - name: platform
+//       gitHubQuery: org:example-platform
+//       iqOrganization: Platform
+//       skipIssueCreation: true
+//
+// A file without targets is a single audit of its settings.
+func loadConfigFile(path string) (map[string]string, []AuditTarget, error) {
+	fileBytes, readError := ioutil.ReadFile(path)
+	if readError != nil {
+		return nil, nil, readError
+	}
+	var file map[string]interface{}
+	var parseError error
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		parseError = json.Unmarshal(fileBytes, &file)
+	} else {
+		parseError = yaml.Unmarshal(fileBytes, &file)
+	}
+	if parseError != nil {
+		return nil, nil, errors.New("unable to read configuration file " + path + " - " + parseError.Error())
+	}
+
+	rawTargets, hasTargets := file["targets"]
+	delete(file, "targets")
+	settings, settingsError := configValues(file)
+	if settingsError != nil {
+		return nil, nil, settingsError
+	}
+	if !hasTargets {
+		return settings, []AuditTarget{{Name: filepath.Base(path), Values: map[string]string{}}}, nil
+	}
+
+	targetList, isList := rawTargets.([]interface{})
+	if !isList || len(targetList) == 0 {
+		return nil, nil, errors.New("targets of configuration file " + path + " must be a list of targets")
+	}
+	var targets []AuditTarget
+	names := make(map[string]bool)
+	for index, rawTarget := range targetList {
+		targetMap, isMap := rawTarget.(map[string]interface{})
+		if !isMap {
+			return nil, nil, fmt.Errorf("target %v of configuration file %v must be a map of settings", index + 1, path)
+		}
+		target := AuditTarget{Name: fmt.Sprintf("target-%v", index + 1)}
+		if name, hasName := targetMap["name"]; hasName {
+			target.Name = fmt.Sprint(name)
+			delete(targetMap, "name")
+		}
+		if names[target.Name] {
+			return nil, nil, errors.New("duplicate target name in configuration file - " + target.Name)
+		}
+		names[target.Name] = true
+		var valuesError error
+		target.Values, valuesError = configValues(targetMap)
+		if valuesError != nil {
+			return nil, nil, errors.New("target " + target.Name + " - " + valuesError.Error())
+		}
+		targets = append(targets, target)
+	}
+	return settings, targets, nil
+}
+
+func configValues(rawValues map[string]interface{}) (map[string]string, error) {
+	values := make(map[string]string)
+	for name, rawValue := range rawValues {
+		if flag.Lookup(name) == nil || name == "config" {
+			return nil, errors.New("unknown configuration setting - " + name)
+		}
+		switch rawValue.(type) {
+		case []interface{}, map[string]interface{}:
+			return nil, errors.New("configuration setting must be a single value - " + name)
+		case nil:
+			continue
+		}
+		values[name] = fmt.Sprint(rawValue)
+	}
+	return values, nil
+}
+
+// applyTarget sets every option from the target, then the shared settings, then its default. Options given on the
+// command line are left alone so they override the file. When there are several targets, their state and report
+// files are named after the target unless the target sets them, so targets do not overwrite each other's.
+func applyTarget(settings map[string]string, target AuditTarget, explicitFlags map[string]bool, multipleTargets bool) error {
+	var names []string
+	flag.VisitAll(func(option *flag.Flag) {
+		names = append(names, option.Name)
+	})
+	sort.Strings(names)
+	for _, name := range names {
+		if explicitFlags[name] || name == "config" {
+			continue
+		}
+		value, found := target.Values[name]
+		if !found {
+			value, found = settings[name]
+		}
+		if !found {
+			value = flag.Lookup(name).DefValue
+		}
+		_, inTarget := target.Values[name]
+		if multipleTargets && !inTarget && len(value) > 0 && (name == "stateFile" || name == "reportFile") {
+			value = targetPath(value, target.Name)
+		}
+		setError := flag.Set(name, value)
+		if setError != nil {
+			return errors.New("target " + target.Name + " - invalid value for " + name + " - " + setError.Error())
+		}
+	}
+	return nil
+}
+
+func targetPath(path string, targetName string) string {
+	extension := filepath.Ext(path)
+	safeName := strings.Map(func(character rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, character) || character == ' ' {
+			return '-'
+		}
+		return character
+	}, targetName)
+	return strings.TrimSuffix(path, extension) + "-" + safeName + extension
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testOptions struct {
+	gitHubQuery string
+	iqOrganization string
+	stateFile string
+	reportFile string
+	skipIssueCreation bool
+}
+
+// newTestFlags replaces the command line flags with a few audit options until the returned function restores them.
+func newTestFlags() (*testOptions, func()) {
+	commandLine := flag.CommandLine
+	flag.CommandLine = flag.NewFlagSet("test", flag.ContinueOnError)
+	options := new(testOptions)
+	flag.StringVar(&options.gitHubQuery, "gitHubQuery", "", "")
+	flag.StringVar(&options.iqOrganization, "iqOrganization", "Root Organization", "")
+	flag.StringVar(&options.stateFile, "stateFile", "", "")
+	flag.StringVar(&options.reportFile, "reportFile", "report.csv", "")
+	flag.BoolVar(&options.skipIssueCreation, "skipIssueCreation", false, "")
+	flag.String("config", "", "")
+	return options, func() { flag.CommandLine = commandLine }
+}
+
+func writeConfigFile(t *testing.T, directory string, name string, content string) string {
+	path := filepath.Join(directory, name)
+	if writeError := ioutil.WriteFile(path, []byte(content), 0600); writeError != nil {
+		t.Fatal(writeError)
+	}
+	return path
+}
+
+func TestLoadConfigFileTargets(t *testing.T) {
+	_, restoreFlags := newTestFlags()
+	defer restoreFlags()
+	directory, directoryError := ioutil.TempDir("", "config")
+	if directoryError != nil {
+		t.Fatal(directoryError)
+	}
+	defer os.RemoveAll(directory)
+	for name, content := range map[string]string{
+		"audit.yml": "iqOrganization: Shared\ntargets:\n  - name: platform\n    gitHubQuery: org:platform\n    skipIssueCreation: true\n  - gitHubQuery: org:web\n",
+		"audit.json": `{"iqOrganization": "Shared", "targets": [{"name": "platform", "gitHubQuery": "org:platform", "skipIssueCreation": true}, {"gitHubQuery": "org:web"}]}`,
+	} {
+		settings, targets, loadError := loadConfigFile(writeConfigFile(t, directory, name, content))
+		if loadError != nil {
+			t.Fatalf("loadConfigFile(%v) returned %v", name, loadError)
+		}
+		if len(settings) != 1 || settings["iqOrganization"] != "Shared" {
+			t.Errorf("loadConfigFile(%v) settings = %v", name, settings)
+		}
+		if len(targets) != 2 || targets[0].Name != "platform" || targets[1].Name != "target-2" {
+			t.Fatalf("loadConfigFile(%v) targets = %v", name, targets)
+		}
+		if targets[0].Values["gitHubQuery"] != "org:platform" || targets[0].Values["skipIssueCreation"] != "true" || targets[1].Values["gitHubQuery"] != "org:web" {
+			t.Errorf("loadConfigFile(%v) target values = %v, %v", name, targets[0].Values, targets[1].Values)
+		}
+	}
+}
+
+func TestLoadConfigFileWithoutTargets(t *testing.T) {
+	_, restoreFlags := newTestFlags()
+	defer restoreFlags()
+	directory, directoryError := ioutil.TempDir("", "config")
+	if directoryError != nil {
+		t.Fatal(directoryError)
+	}
+	defer os.RemoveAll(directory)
+	settings, targets, loadError := loadConfigFile(writeConfigFile(t, directory, "audit.yaml", "gitHubQuery: org:example\n"))
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+	if settings["gitHubQuery"] != "org:example" || len(targets) != 1 || targets[0].Name != "audit.yaml" || len(targets[0].Values) != 0 {
+		t.Errorf("loadConfigFile() = %v, %v", settings, targets)
+	}
+}
+
+func TestLoadConfigFileInvalid(t *testing.T) {
+	_, restoreFlags := newTestFlags()
+	defer restoreFlags()
+	directory, directoryError := ioutil.TempDir("", "config")
+	if directoryError != nil {
+		t.Fatal(directoryError)
+	}
+	defer os.RemoveAll(directory)
+	for _, content := range []string{
+		"unknownSetting: value\n",
+		"config: other.yml\n",
+		"gitHubQuery:\n  - org:one\n",
+		"targets: org:example\n",
+		"targets:\n  - name: one\n  - name: one\n",
+		"targets:\n  - name: one\n    iqOrganizatoin: typo\n",
+	} {
+		if _, _, loadError := loadConfigFile(writeConfigFile(t, directory, "audit.yml", content)); loadError == nil {
+			t.Errorf("loadConfigFile(%q) returned no error", content)
+		}
+	}
+}
+
+func TestApplyTarget(t *testing.T) {
+	options, restoreFlags := newTestFlags()
+	defer restoreFlags()
+	settings := map[string]string{"iqOrganization": "Shared", "stateFile": "state.json", "skipIssueCreation": "true"}
+	explicitFlags := map[string]bool{"gitHubQuery": true}
+	if setError := flag.Set("gitHubQuery", "org:command-line"); setError != nil {
+		t.Fatal(setError)
+	}
+
+	target := AuditTarget{Name: "web team", Values: map[string]string{"iqOrganization": "Web", "skipIssueCreation": "false", "gitHubQuery": "org:web"}}
+	if applyError := applyTarget(settings, target, explicitFlags, true); applyError != nil {
+		t.Fatal(applyError)
+	}
+	if options.gitHubQuery != "org:command-line" {
+		t.Errorf("gitHubQuery = %q, the command line should override the file", options.gitHubQuery)
+	}
+	if options.iqOrganization != "Web" || options.skipIssueCreation {
+		t.Errorf("iqOrganization, skipIssueCreation = %q, %v, the target should override the shared settings", options.iqOrganization, options.skipIssueCreation)
+	}
+	if options.stateFile != "state-web-team.json" || options.reportFile != "report-web-team.csv" {
+		t.Errorf("stateFile, reportFile = %q, %q, want them named after the target", options.stateFile, options.reportFile)
+	}
+
+	// Values of the previous target are reset to the shared settings and defaults
+	if applyError := applyTarget(settings, AuditTarget{Name: "other", Values: map[string]string{"reportFile": "other.csv"}}, explicitFlags, false); applyError != nil {
+		t.Fatal(applyError)
+	}
+	if options.iqOrganization != "Shared" || !options.skipIssueCreation || options.stateFile != "state.json" || options.reportFile != "other.csv" {
+		t.Errorf("options = %+v, want the shared settings", *options)
+	}
+
+	if applyError := applyTarget(settings, AuditTarget{Name: "invalid", Values: map[string]string{"skipIssueCreation": "sometimes"}}, explicitFlags, false); applyError == nil {
+		t.Errorf("applyTarget() with an invalid bool returned no error")
+	}
+}
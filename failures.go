@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+type Failures struct {
+	Rows []*ReportRow
+}
+
+// Add records the error against the repository's report row, a repository that failed before an IQ application
+// was found or created is reported as failed.
+func (failures *Failures) Add(row *ReportRow, err error) {
+	log.Println("Audit failed, Continuing - " + row.Repository + " - " + err.Error())
+	if len(row.Status) == 0 {
+		row.Status = StatusFailed
+	}
+	row.Error = err.Error()
+	failures.Rows = append(failures.Rows, row)
+}
+
+func (failures *Failures) Print() {
+	if len(failures.Rows) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "\nFailed Repositories - %v\n", len(failures.Rows))
+	for _, row := range failures.Rows {
+		_, _ = fmt.Fprint(os.Stdout, "\n"+row.Repository+"\n")
+		_, _ = fmt.Fprint(os.Stdout, "  - "+row.Error+"\n")
+	}
+}
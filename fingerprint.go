@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"iq-scm-audit/scm"
+	"sort"
+	"strings"
+)
+
+const (
+	ChangeNone = "none"
+	ChangeNew = "new"
+	ChangeChanged = "changed"
+	ChangeUnchanged = "unchanged"
+)
+
+// Fingerprint identifies what was last scanned and evaluated for a repository along with the results, an incremental
+// audit reuses the results while the fingerprint stays the same.
+type Fingerprint struct {
+	Dependencies string `json:"dependencies,omitempty"`
+	SbomPolicyAction string `json:"sbomPolicyAction,omitempty"`
+	AuditReportUrl string `json:"auditReportUrl,omitempty"`
+	Release string `json:"release,omitempty"`
+	ReleaseReportUrl string `json:"releaseReportUrl,omitempty"`
+	Package string `json:"package,omitempty"`
+	PackageReportUrl string `json:"packageReportUrl,omitempty"`
+}
+
+// The dependency graph is fingerprinted in sorted order, so dependencies reported in a different order are unchanged.
+func dependenciesFingerprint(dependencies []scm.Dependency) string {
+	var lines []string
+	for _, dependency := range dependencies {
+		lines = append(lines, dependency.PackageManager + "\t" + dependency.PackageName + "\t" + dependency.Requirements)
+	}
+	return fingerprintOf(lines)
+}
+
+// Asset Urls carry the release tag, build or package version, so a new release changes the fingerprint.
+func assetsFingerprint(assets []scm.Asset) string {
+	var lines []string
+	for _, asset := range assets {
+		lines = append(lines, asset.Name + "\t" + asset.Url)
+	}
+	return fingerprintOf(lines)
+}
+
+func fingerprintOf(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	sort.Strings(lines)
+	hash := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(hash[:])
+}
+
+func changeOf(previous string, current string) string {
+	switch {
+	case len(current) == 0:
+		return ChangeNone
+	case len(previous) == 0:
+		return ChangeNew
+	case previous == current:
+		return ChangeUnchanged
+	}
+	return ChangeChanged
+}
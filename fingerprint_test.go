@@ -0,0 +1,63 @@
+package main
+
+import (
+	"iq-scm-audit/scm"
+	"testing"
+)
+
+func TestDependenciesFingerprint(t *testing.T) {
+	lodash := scm.Dependency{PackageManager: "NPM", PackageName: "lodash", Requirements: "4.17.21"}
+	react := scm.Dependency{PackageManager: "NPM", PackageName: "react", Requirements: "18.2.0"}
+	fingerprint := dependenciesFingerprint([]scm.Dependency{lodash, react})
+	if len(fingerprint) != 64 {
+		t.Fatalf("dependenciesFingerprint() = %q, want a SHA-256", fingerprint)
+	}
+	if reordered := dependenciesFingerprint([]scm.Dependency{react, lodash}); reordered != fingerprint {
+		t.Errorf("dependenciesFingerprint() of reordered dependencies = %q, want %q", reordered, fingerprint)
+	}
+	upgraded := lodash
+	upgraded.Requirements = "4.17.22"
+	if changed := dependenciesFingerprint([]scm.Dependency{upgraded, react}); changed == fingerprint {
+		t.Errorf("dependenciesFingerprint() of an upgraded dependency is unchanged")
+	}
+	if removed := dependenciesFingerprint([]scm.Dependency{lodash}); removed == fingerprint {
+		t.Errorf("dependenciesFingerprint() without a dependency is unchanged")
+	}
+	if empty := dependenciesFingerprint(nil); empty != "" {
+		t.Errorf("dependenciesFingerprint(nil) = %q, want empty", empty)
+	}
+}
+
+func TestAssetsFingerprint(t *testing.T) {
+	release := []scm.Asset{{Name: "app.jar", Url: "https://github.com/owner/repository/releases/download/v1.0.0/app.jar"}}
+	nextRelease := []scm.Asset{{Name: "app.jar", Url: "https://github.com/owner/repository/releases/download/v1.1.0/app.jar"}}
+	fingerprint := assetsFingerprint(release)
+	if fingerprint != assetsFingerprint(release) {
+		t.Errorf("assetsFingerprint() is not stable")
+	}
+	if assetsFingerprint(nextRelease) == fingerprint {
+		t.Errorf("assetsFingerprint() of a new release is unchanged")
+	}
+	if empty := assetsFingerprint(nil); empty != "" {
+		t.Errorf("assetsFingerprint(nil) = %q, want empty", empty)
+	}
+}
+
+func TestChangeOf(t *testing.T) {
+	tests := []struct {
+		previous string
+		current string
+		change string
+	}{
+		{"", "", ChangeNone},
+		{"abc", "", ChangeNone},
+		{"", "abc", ChangeNew},
+		{"abc", "abc", ChangeUnchanged},
+		{"abc", "def", ChangeChanged},
+	}
+	for _, test := range tests {
+		if change := changeOf(test.previous, test.current); change != test.change {
+			t.Errorf("changeOf(%q, %q) = %q, want %q", test.previous, test.current, change, test.change)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"golang.org/x/oauth2"
+	auditHttp "iq-scm-audit/http"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+const installationsEndpoint = "/app/installations"
+const installationTokenEndpoint = "/app/installations/%v/access_tokens"
+
+type GitHubApp struct {
+	AppId string
+	PrivateKey *rsa.PrivateKey
+}
+
+type Installation struct {
+	Id int64
+	Account struct {
+		Login string
+	}
+}
+
+type InstallationToken struct {
+	Token string
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type installationTokenSource struct {
+	client *GitHubClient
+	installationId int64
+}
+
+func NewGitHubApp(appId string, privateKeyFile string) (*GitHubApp, error) {
+	keyBytes, readError := ioutil.ReadFile(privateKeyFile)
+	if readError != nil {
+		return nil, readError
+	}
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, errors.New("GitHub App private key is not PEM encoded - " + privateKeyFile)
+	}
+	privateKey, parseError := parsePrivateKey(block.Bytes)
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	gitHubApp := new(GitHubApp)
+	gitHubApp.AppId = appId
+	gitHubApp.PrivateKey = privateKey
+	return gitHubApp, nil
+}
+
+func NewGitHubAppClient(baseUrl string, app *GitHubApp, installationId int64) *GitHubClient {
+	var gitHubClient = NewGitHubClient(baseUrl, "")
+	gitHubClient.App = app
+	gitHubClient.InstallationId = installationId
+	gitHubClient.installationTokenSources = make(map[string]oauth2.TokenSource)
+	return gitHubClient
+}
+
+func (client *GitHubClient) GetInstallations() ([]Installation, error) {
+	var installations []Installation
+	for page := 1; ; page++ {
+		getBytes, getError := client.getAppHttpClient().HttpGet(client.restUrl() + installationsEndpoint + fmt.Sprintf("?per_page=100&page=%v", page))
+		if getError != nil {
+			return nil, getError
+		}
+		var pageInstallations []Installation
+		unmarshalError := json.Unmarshal(getBytes, &pageInstallations)
+		if unmarshalError != nil {
+			return nil, unexpectedResponse(getBytes)
+		}
+		installations = append(installations, pageInstallations...)
+		if len(pageInstallations) < 100 {
+			break
+		}
+	}
+	return installations, nil
+}
+
+func (client *GitHubClient) newInstallationTokenSource(installationId int64) oauth2.TokenSource {
+	source := new(installationTokenSource)
+	source.client = client
+	source.installationId = installationId
+	return oauth2.ReuseTokenSource(nil, source)
+}
+
+// Installation tokens expire after an hour, ReuseTokenSource calls Token again to mint a new one once expired.
+func (source *installationTokenSource) Token() (*oauth2.Token, error) {
+	client := source.client
+	postBytes, postError := client.getAppHttpClient().HttpPost(client.restUrl() + fmt.Sprintf(installationTokenEndpoint, source.installationId), map[string]string {})
+	if postError != nil {
+		return nil, postError
+	}
+	installationToken := new(InstallationToken)
+	unmarshalError := json.Unmarshal(postBytes, &installationToken)
+	if unmarshalError != nil || len(installationToken.Token) == 0 {
+		return nil, errors.New("unable to create GitHub App installation token - " + string(postBytes))
+	}
+	return &oauth2.Token{AccessToken: installationToken.Token, Expiry: installationToken.ExpiresAt}, nil
+}
+
+func (client *GitHubClient) getAppHttpClient() *auditHttp.HttpClient {
+	httpClient := new(auditHttp.HttpClient)
+	httpClient.TokenSource = client.App
+	return httpClient
+}
+
+// Token signs a JWT authenticating as the GitHub App itself, used to list installations and mint installation tokens.
+func (app *GitHubApp) Token() (*oauth2.Token, error) {
+	jwt, signError := app.jwt()
+	if signError != nil {
+		return nil, signError
+	}
+	return &oauth2.Token{AccessToken: jwt}, nil
+}
+
+func (app *GitHubApp) jwt() (string, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string {
+		"alg": "RS256",
+		"typ": "JWT",
+	})
+	// Issued in the past to allow for clock drift, GitHub rejects tokens that expire more than ten minutes out
+	claims, _ := json.Marshal(map[string]interface{} {
+		"iat": now.Add(-60 * time.Second).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": app.AppId,
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, signError := rsa.SignPKCS1v15(rand.Reader, app.PrivateKey, crypto.SHA256, hash[:])
+	if signError != nil {
+		return "", signError
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+type errorTokenSource struct {
+	err error
+}
+
+func (source *errorTokenSource) Token() (*oauth2.Token, error) {
+	return nil, source.err
+}
+
+func parsePrivateKey(keyBytes []byte) (*rsa.PrivateKey, error) {
+	privateKey, pkcs1Error := x509.ParsePKCS1PrivateKey(keyBytes)
+	if pkcs1Error == nil {
+		return privateKey, nil
+	}
+	key, pkcs8Error := x509.ParsePKCS8PrivateKey(keyBytes)
+	if pkcs8Error != nil {
+		return nil, pkcs1Error
+	}
+	rsaKey, isRsa := key.(*rsa.PrivateKey)
+	if !isRsa {
+		return nil, errors.New("GitHub App private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+func ownerOf(nameWithOwner string) string {
+	return strings.ToLower(strings.SplitN(nameWithOwner, "/", 2)[0])
+}
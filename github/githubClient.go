@@ -2,21 +2,49 @@ package github
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/shurcooL/githubv4"
 	"golang.org/x/oauth2"
 	auditHttp "iq-scm-audit/http"
+	"iq-scm-audit/scm"
 	"log"
 	"net/http"
-	"os"
+	"regexp"
+	"strings"
+	"sync"
 )
 
+const Provider = "github"
+const CloudUrl = "https://github.com"
 const cloudApiUrl = "https://api.github.com"
 const graphQlEndpoint = "/graphql"
+const enterpriseGraphQlEndpoint = "/api/graphql"
+const enterpriseRestEndpoint = "/api/v3"
 const issueEndpoint = "/repos/%v/issues"
+const openIssuesEndpoint = issueEndpoint + "?state=open&per_page=100&page=%v"
+const updateIssueEndpoint = issueEndpoint + "/%v"
+const issueCommentEndpoint = issueEndpoint + "/%v/comments"
+const issueMarker = "<!-- iq-scm-audit -->"
+const releaseByTagEndpoint = "/repos/%v/releases/tags/%v"
+
+// GitHub Packages registries, e.g. https://maven.pkg.github.com/owner/repository/..., the path starts with the owner
+var cloudPackageRegistryPattern = regexp.MustCompile(`^https://[a-z]+\.pkg\.github\.com/(.+)$`)
 
 type GitHubClient struct {
+	BaseUrl string
 	Token string
+	App *GitHubApp
+	InstallationId int64
+	installationTokenSources map[string]oauth2.TokenSource
+	installations []Installation
+	installationsLoaded bool
+	installationTokenSourcesLock sync.Mutex
+	// Public emails of the users looked up so far, users maintain many repositories
+	userEmails map[string]string
+	userEmailsLock sync.Mutex
+	Limiter auditHttp.Limiter
 }
 
 type (
@@ -36,6 +64,18 @@ type Repository struct {
 		NameWithOwner string
 		Url string
 		SshUrl string
+		IsPrivate bool
+		IsArchived bool
+		PrimaryLanguage struct {
+			Name string
+		}
+		RepositoryTopics struct {
+			Nodes[] struct {
+				Topic struct {
+					Name string
+				}
+			}
+		} `graphql:"repositoryTopics(first: 20)"`
 		DependencyGraphManifests DependencyGraphManifests
 		Packages Packages `graphql:"packages(last: 1)"`
 		Releases Releases `graphql:"releases(last: 1)"`
@@ -85,42 +125,101 @@ type (
 	}
 )
 
-type Dependency struct {
-	PackageManager string
-	PackageName    string
-	Requirements   string
+type Dependency = scm.Dependency
+
+type Release struct {
+	Assets []struct {
+		Name string
+		// The REST API Url of the asset, which returns its content for application/octet-stream
+		Url string
+	}
+}
+
+type Issue struct {
+	Number int
+	Body string
+	HtmlUrl string `json:"html_url"`
+	PullRequest *struct{} `json:"pull_request"`
 }
 
 type transport struct {}
 
-func NewGitHubClient(token string) *GitHubClient {
+func NewGitHubClient(baseUrl string, token string) *GitHubClient {
 	var gitHubClient = new(GitHubClient)
+	gitHubClient.BaseUrl = strings.TrimSuffix(baseUrl, "/")
 	gitHubClient.Token = token
 	return gitHubClient
 }
 
-func(client *GitHubClient) GetRepositories(query string) []Repository {
-	httpClient := newGraphQlClient(client.Token)
+func (client *GitHubClient) Provider() string {
+	return Provider
+}
+
+func (client *GitHubClient) ServerUrl() string {
+	if client.isEnterprise() {
+		return client.BaseUrl
+	}
+	return ""
+}
+
+func (client *GitHubClient) GetRepositories(query string) ([]scm.Repository, error) {
+	var allRepositories []Repository
+	if client.App != nil && client.InstallationId == 0 {
+		client.installationTokenSourcesLock.Lock()
+		installations, installationsError := client.loadInstallations()
+		client.installationTokenSourcesLock.Unlock()
+		if installationsError != nil {
+			return nil, installationsError
+		}
+		for _, installation := range installations {
+			log.Println("Getting GitHub Repositories for installation - " + installation.Account.Login)
+			source := client.tokenSourceFor(installation.Account.Login + "/")
+			installationRepositories, searchError := client.searchRepositories(query, source)
+			if searchError != nil {
+				return nil, searchError
+			}
+			// Search results include public repositories of other accounts, keep those this installation owns
+			for _, repository := range installationRepositories {
+				if ownerOf(repository.RepositoryFragment.NameWithOwner) == strings.ToLower(installation.Account.Login) {
+					allRepositories = append(allRepositories, repository)
+				}
+			}
+		}
+	} else {
+		var searchError error
+		allRepositories, searchError = client.searchRepositories(query, client.tokenSourceFor(""))
+		if searchError != nil {
+			return nil, searchError
+		}
+	}
+
+	var repositories []scm.Repository
+	for _, repository := range allRepositories {
+		repositories = append(repositories, *repository.toScmRepository())
+	}
+	return repositories, nil
+}
+
+func (client *GitHubClient) searchRepositories(query string, source oauth2.TokenSource) ([]Repository, error) {
+	httpClient := newGraphQlClient(client.graphQlUrl(), source)
 	variables := map[string] interface {} {
 		"queryString": githubv4.String(query + " fork:true"),
 		"repositoryCursor":  (*githubv4.String)(nil),
 	}
 	var allRepositories []Repository
-	var errors []string
+	var queryErrors []string
 	for {
 		var query struct {
 			Search RepositorySearch `graphql:"search(query: $queryString, type: REPOSITORY, first: 5, after: $repositoryCursor)"`
 		}
 		err := httpClient.Query(context.Background(), &query, variables)
 		if err != nil {
-			errors = append(errors, err.Error())
-			if len(errors) > 9 {
-				log.Print("GraphQL Query to GitHub failed.")
-				for _, queuedError := range errors {
-					log.Print(queuedError)
-				}
-				os.Exit(1)
+			// Retry the same page, GitHub intermittently times out resolving dependency graphs
+			queryErrors = append(queryErrors, err.Error())
+			if len(queryErrors) > 9 {
+				return nil, errors.New("GraphQL Query to GitHub failed - " + strings.Join(queryErrors, ", "))
 			}
+			continue
 		}
 		allRepositories = append(allRepositories, query.Search.Nodes...)
 		if !query.Search.PageInfo.HasNextPage {
@@ -128,40 +227,253 @@ func(client *GitHubClient) GetRepositories(query string) []Repository {
 		}
 		variables["repositoryCursor"] = githubv4.NewString(query.Search.PageInfo.EndCursor)
 	}
-	return allRepositories
+	return allRepositories, nil
 }
 
-func (client *GitHubClient) CreateIssue(repositoryNameWithOwner string, title string, markdown string) {
-	httpClient := newHttpClient(client.Token)
-	httpClient.HttpPost(cloudApiUrl + fmt.Sprintf(issueEndpoint, repositoryNameWithOwner), map[string] string {
+// Issues opened by the tool carry a hidden marker, re-running updates the open issue instead of opening another.
+func (client *GitHubClient) CreateIssue(repositoryNameWithOwner string, title string, markdown string) (string, error) {
+	httpClient := client.newHttpClient(client.tokenSourceFor(repositoryNameWithOwner))
+	body := markdown + "\n\n" + issueMarker
+	existingIssue, findError := client.findIssue(repositoryNameWithOwner)
+	if findError != nil {
+		return "", findError
+	}
+	if existingIssue == nil {
+		postBytes, postError := httpClient.HttpPost(client.restUrl() + fmt.Sprintf(issueEndpoint, repositoryNameWithOwner), map[string] string {
+			"title": title,
+			"body": body,
+		})
+		if postError != nil {
+			return "", postError
+		}
+		issue := new(Issue)
+		unmarshalError := json.Unmarshal(postBytes, &issue)
+		if unmarshalError != nil || issue.Number == 0 {
+			return "", unexpectedResponse(postBytes)
+		}
+		return issue.HtmlUrl, nil
+	}
+
+	if existingIssue.Body == body {
+		log.Println(fmt.Sprintf("Existing issue is up to date, Skipping - %v#%v", repositoryNameWithOwner, existingIssue.Number))
+		return existingIssue.HtmlUrl, nil
+	}
+	log.Println(fmt.Sprintf("Updating existing issue - %v#%v", repositoryNameWithOwner, existingIssue.Number))
+	_, patchError := httpClient.HttpPatch(client.restUrl() + fmt.Sprintf(updateIssueEndpoint, repositoryNameWithOwner, existingIssue.Number), map[string] string {
 		"title": title,
-		"body": markdown,
+		"body": body,
 	})
+	if patchError != nil {
+		return "", patchError
+	}
+	changes := changedReportLines(existingIssue.Body, body)
+	if len(changes) > 0 {
+		_, commentError := httpClient.HttpPost(client.restUrl() + fmt.Sprintf(issueCommentEndpoint, repositoryNameWithOwner, existingIssue.Number), map[string] string {
+			"body": "Nexus IQ has re-audited this repository. The following reports changed since the last audit:\n\n" + strings.Join(changes, "\n"),
+		})
+		if commentError != nil {
+			return "", commentError
+		}
+	}
+	return existingIssue.HtmlUrl, nil
 }
 
-func (client *GitHubClient) DownloadRelease(url string) []byte {
+func (client *GitHubClient) findIssue(repositoryNameWithOwner string) (*Issue, error) {
+	httpClient := client.newHttpClient(client.tokenSourceFor(repositoryNameWithOwner))
+	for page := 1; ; page++ {
+		getBytes, getError := httpClient.HttpGet(client.restUrl() + fmt.Sprintf(openIssuesEndpoint, repositoryNameWithOwner, page))
+		if getError != nil {
+			return nil, getError
+		}
+		var issues []Issue
+		unmarshalError := json.Unmarshal(getBytes, &issues)
+		if unmarshalError != nil {
+			return nil, unexpectedResponse(getBytes)
+		}
+		for _, issue := range issues {
+			if issue.PullRequest == nil && strings.Contains(issue.Body, issueMarker) {
+				return &issue, nil
+			}
+		}
+		if len(issues) < 100 {
+			return nil, nil
+		}
+	}
+}
+
+func changedReportLines(previousBody string, body string) []string {
+	previousLines := make(map[string]bool)
+	for _, line := range strings.Split(previousBody, "\n") {
+		previousLines[strings.TrimSpace(line)] = true
+	}
+	var changes []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[Application Report") && !previousLines[line] {
+			changes = append(changes, "- " + line)
+		}
+	}
+	return changes
+}
+
+// Assets of private repositories require authentication, the token is only sent to GitHub hosts and GitHub Packages
+// registries and dropped when they redirect to a signed download Url.
+func (client *GitHubClient) DownloadRelease(url string) ([]byte, error) {
+	if client.isEnterprise() && strings.HasPrefix(url, client.BaseUrl + "/") {
+		return client.newHttpClient(client.tokenSourceFor(strings.TrimPrefix(url, client.BaseUrl + "/"))).HttpGet(url)
+	}
+	if !client.isEnterprise() && strings.HasPrefix(url, CloudUrl + "/") {
+		return client.downloadCloudAsset(strings.TrimPrefix(url, CloudUrl + "/"))
+	}
+	if registryPath := cloudPackageRegistryPattern.FindStringSubmatch(url); !client.isEnterprise() && registryPath != nil {
+		return client.newHttpClient(client.tokenSourceFor(registryPath[1])).HttpGet(url)
+	}
 	httpClient := new(auditHttp.HttpClient)
+	httpClient.Limiter = client.Limiter
 	return httpClient.HttpGet(url)
 }
 
+// downloadCloudAsset gets owner/repository/releases/download/tag/name through the REST API, github.com only serves
+// assets of private repositories there.
+func (client *GitHubClient) downloadCloudAsset(path string) ([]byte, error) {
+	httpClient := client.newHttpClient(client.tokenSourceFor(path))
+	parts := strings.SplitN(path, "/", 6)
+	if len(parts) < 6 || parts[2] != "releases" || parts[3] != "download" {
+		return httpClient.HttpGet(CloudUrl + "/" + path)
+	}
+	getBytes, getError := httpClient.HttpGet(client.restUrl() + fmt.Sprintf(releaseByTagEndpoint, parts[0] + "/" + parts[1], parts[4]))
+	if getError != nil {
+		return nil, getError
+	}
+	var release Release
+	unmarshalError := json.Unmarshal(getBytes, &release)
+	if unmarshalError != nil {
+		return nil, unmarshalError
+	}
+	for _, asset := range release.Assets {
+		if asset.Name == parts[5] {
+			return httpClient.HttpDownload(asset.Url)
+		}
+	}
+	return nil, errors.New("release asset not found - " + CloudUrl + "/" + path)
+}
+
+// Repositories are found through the installation of their owner when looping over every installation of a GitHub App.
+func (client *GitHubClient) tokenSourceFor(nameWithOwner string) oauth2.TokenSource {
+	if client.App == nil {
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: client.Token})
+	}
+	client.installationTokenSourcesLock.Lock()
+	defer client.installationTokenSourcesLock.Unlock()
+	if client.InstallationId == 0 {
+		// Resumed runs never list repositories, the installations are listed on first use instead
+		if _, installationsError := client.loadInstallations(); installationsError != nil {
+			return &errorTokenSource{err: installationsError}
+		}
+		source, found := client.installationTokenSources[ownerOf(nameWithOwner)]
+		if !found {
+			return &errorTokenSource{err: errors.New("no GitHub App installation found for - " + nameWithOwner)}
+		}
+		return source
+	}
+	source, found := client.installationTokenSources[""]
+	if !found {
+		source = client.newInstallationTokenSource(client.InstallationId)
+		client.installationTokenSources[""] = source
+	}
+	return source
+}
+
+// Lists the installations of the GitHub App once and keeps a token source for each account, the caller holds installationTokenSourcesLock.
+func (client *GitHubClient) loadInstallations() ([]Installation, error) {
+	if client.installationsLoaded {
+		return client.installations, nil
+	}
+	installations, installationsError := client.GetInstallations()
+	if installationsError != nil {
+		return nil, installationsError
+	}
+	for _, installation := range installations {
+		client.installationTokenSources[strings.ToLower(installation.Account.Login)] = client.newInstallationTokenSource(installation.Id)
+	}
+	client.installations = installations
+	client.installationsLoaded = true
+	return installations, nil
+}
+
+func (client *GitHubClient) isEnterprise() bool {
+	return len(client.BaseUrl) > 0 && client.BaseUrl != CloudUrl && client.BaseUrl != cloudApiUrl
+}
+
+func (client *GitHubClient) graphQlUrl() string {
+	if client.isEnterprise() {
+		return client.BaseUrl + enterpriseGraphQlEndpoint
+	}
+	return cloudApiUrl + graphQlEndpoint
+}
+
+func (client *GitHubClient) restUrl() string {
+	if client.isEnterprise() {
+		return client.BaseUrl + enterpriseRestEndpoint
+	}
+	return cloudApiUrl
+}
+
+func (repository *Repository) toScmRepository() *scm.Repository {
+	fragment := repository.RepositoryFragment
+	scmRepository := new(scm.Repository)
+	scmRepository.Name = fragment.Name
+	scmRepository.NameWithOwner = fragment.NameWithOwner
+	scmRepository.Url = fragment.Url
+	scmRepository.SshUrl = fragment.SshUrl
+	for _, topic := range fragment.RepositoryTopics.Nodes {
+		scmRepository.Topics = append(scmRepository.Topics, topic.Topic.Name)
+	}
+	scmRepository.Language = fragment.PrimaryLanguage.Name
+	scmRepository.Visibility = "public"
+	if fragment.IsPrivate {
+		scmRepository.Visibility = "private"
+	}
+	scmRepository.Archived = fragment.IsArchived
+	for _, manifest := range fragment.DependencyGraphManifests.Nodes {
+		scmRepository.Manifests = append(scmRepository.Manifests, scm.Manifest{
+			Filename: manifest.Filename,
+			Dependencies: manifest.Dependencies.Nodes,
+		})
+	}
+	if len(fragment.Releases.Nodes) > 0 {
+		for _, asset := range fragment.Releases.Nodes[0].ReleaseAssets.Nodes {
+			scmRepository.ReleaseAssets = append(scmRepository.ReleaseAssets, scm.Asset{Name: asset.Name, Url: asset.Url})
+		}
+	}
+	if len(fragment.Packages.Nodes) > 0 {
+		for _, file := range fragment.Packages.Nodes[0].LatestVersion.Files.Nodes {
+			scmRepository.PackageFiles = append(scmRepository.PackageFiles, scm.Asset{Name: file.Name, Url: file.Url})
+		}
+	}
+	return scmRepository
+}
+
 func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	req.Header.Add("Accept", "application/vnd.github.hawkgirl-preview+json")
 	req.Header.Add("Accept", "application/vnd.github.packages-preview+json")
 	return http.DefaultTransport.RoundTrip(req)
 }
 
-func newGraphQlClient(token string) *githubv4.Client {
+func newGraphQlClient(url string, source oauth2.TokenSource) *githubv4.Client {
 	httpClient := &http.Client{Transport: &transport{}}
 	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
-	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
-	oauthClient := oauth2.NewClient(ctx, src)
-	return githubv4.NewEnterpriseClient(cloudApiUrl+graphQlEndpoint, oauthClient)
+	oauthClient := oauth2.NewClient(ctx, source)
+	return githubv4.NewEnterpriseClient(url, oauthClient)
 }
 
-func newHttpClient(token string) *auditHttp.HttpClient {
-	client := new(auditHttp.HttpClient)
-	client.Token = token
-	return client
+func unexpectedResponse(responseBytes []byte) error {
+	return errors.New("unexpected response from GitHub - " + string(responseBytes))
+}
+
+func (client *GitHubClient) newHttpClient(source oauth2.TokenSource) *auditHttp.HttpClient {
+	httpClient := new(auditHttp.HttpClient)
+	httpClient.TokenSource = source
+	httpClient.Limiter = client.Limiter
+	return httpClient
 }
\ No newline at end of file
@@ -0,0 +1,145 @@
+package github
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	auditHttp "iq-scm-audit/http"
+	"log"
+	"net/url"
+)
+
+const repositoryEndpoint = "/repos/%v"
+const branchRefEndpoint = repositoryEndpoint + "/git/ref/heads/%v"
+const refsEndpoint = repositoryEndpoint + "/git/refs"
+const updateBranchRefEndpoint = repositoryEndpoint + "/git/refs/heads/%v"
+const contentsEndpoint = repositoryEndpoint + "/contents/%v"
+const pullsEndpoint = repositoryEndpoint + "/pulls"
+const openPullsEndpoint = pullsEndpoint + "?state=open&head=%v"
+
+type RepositoryDetails struct {
+	DefaultBranch string `json:"default_branch"`
+	Owner struct {
+		Login string
+	}
+}
+
+type Ref struct {
+	Ref string
+	Object struct {
+		Sha string
+	}
+}
+
+type Content struct {
+	Sha string
+}
+
+type PullRequest struct {
+	Number int
+	HtmlUrl string `json:"html_url"`
+}
+
+// CreatePullRequest commits a single file to a branch created from the default branch and opens a pull request for it.
+// An open pull request for the branch is left as is, a branch without one is reset to the default branch first.
+func (client *GitHubClient) CreatePullRequest(repositoryNameWithOwner string, branch string, path string, content string, title string, markdown string) (string, error) {
+	httpClient := client.newHttpClient(client.tokenSourceFor(repositoryNameWithOwner))
+	restUrl := client.restUrl()
+
+	getBytes, getError := httpClient.HttpGet(restUrl + fmt.Sprintf(repositoryEndpoint, repositoryNameWithOwner))
+	if getError != nil {
+		return "", getError
+	}
+	repositoryDetails := new(RepositoryDetails)
+	unmarshalError := json.Unmarshal(getBytes, &repositoryDetails)
+	if unmarshalError != nil || len(repositoryDetails.DefaultBranch) == 0 {
+		return "", unexpectedResponse(getBytes)
+	}
+
+	getBytes, getError = httpClient.HttpGet(restUrl + fmt.Sprintf(openPullsEndpoint, repositoryNameWithOwner, url.QueryEscape(repositoryDetails.Owner.Login + ":" + branch)))
+	if getError != nil {
+		return "", getError
+	}
+	var pullRequests []PullRequest
+	unmarshalError = json.Unmarshal(getBytes, &pullRequests)
+	if unmarshalError != nil {
+		return "", unexpectedResponse(getBytes)
+	}
+	if len(pullRequests) > 0 {
+		log.Println("Existing pull request open, Skipping - " + pullRequests[0].HtmlUrl)
+		return pullRequests[0].HtmlUrl, nil
+	}
+
+	getBytes, getError = httpClient.HttpGet(restUrl + fmt.Sprintf(branchRefEndpoint, repositoryNameWithOwner, url.PathEscape(repositoryDetails.DefaultBranch)))
+	if getError != nil {
+		return "", getError
+	}
+	defaultBranchRef := new(Ref)
+	unmarshalError = json.Unmarshal(getBytes, &defaultBranchRef)
+	if unmarshalError != nil || len(defaultBranchRef.Object.Sha) == 0 {
+		return "", unexpectedResponse(getBytes)
+	}
+
+	// GitHub rejects creating a branch that already exists, a branch left from a closed pull request is force updated to
+	// the default branch, so the pull request has neither a stale base nor a stale workflow
+	_, postError := httpClient.HttpPost(restUrl + fmt.Sprintf(refsEndpoint, repositoryNameWithOwner), map[string]string {
+		"ref": "refs/heads/" + branch,
+		"sha": defaultBranchRef.Object.Sha,
+	})
+	if auditHttp.IsStatus(postError, 422) {
+		log.Println("Resetting existing branch to " + repositoryDetails.DefaultBranch + " - " + repositoryNameWithOwner + ":" + branch)
+		_, postError = httpClient.HttpPatch(restUrl + fmt.Sprintf(updateBranchRefEndpoint, repositoryNameWithOwner, url.PathEscape(branch)), map[string]interface{} {
+			"sha": defaultBranchRef.Object.Sha,
+			"force": true,
+		})
+	}
+	if postError != nil {
+		return "", postError
+	}
+
+	contentUrl := restUrl + fmt.Sprintf(contentsEndpoint, repositoryNameWithOwner, path)
+	getBytes, getError = httpClient.HttpGet(contentUrl + "?ref=" + url.QueryEscape(branch))
+	if getError != nil && !auditHttp.IsStatus(getError, 404) {
+		return "", getError
+	}
+	existingContent := new(Content)
+	if getError == nil {
+		_ = json.Unmarshal(getBytes, &existingContent)
+	}
+	putBody := map[string]string {
+		"message": title,
+		"content": base64.StdEncoding.EncodeToString([]byte(content)),
+		"branch": branch,
+	}
+	if len(existingContent.Sha) > 0 {
+		putBody["sha"] = existingContent.Sha
+	}
+	putBytes, putError := httpClient.HttpPut(contentUrl, putBody)
+	if putError != nil {
+		return "", putError
+	}
+	committedContent := new(struct {
+		Content Content
+	})
+	unmarshalError = json.Unmarshal(putBytes, &committedContent)
+	if unmarshalError != nil || len(committedContent.Content.Sha) == 0 {
+		return "", unexpectedResponse(putBytes)
+	}
+
+	postBytes, postError := httpClient.HttpPost(restUrl + fmt.Sprintf(pullsEndpoint, repositoryNameWithOwner), map[string]string {
+		"title": title,
+		"head": branch,
+		"base": repositoryDetails.DefaultBranch,
+		"body": markdown,
+	})
+	if postError != nil {
+		return "", postError
+	}
+	pullRequest := new(PullRequest)
+	unmarshalError = json.Unmarshal(postBytes, &pullRequest)
+	if unmarshalError != nil || pullRequest.Number == 0 {
+		return "", unexpectedResponse(postBytes)
+	}
+	log.Println("Opened pull request - " + pullRequest.HtmlUrl)
+	return pullRequest.HtmlUrl, nil
+}
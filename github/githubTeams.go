@@ -0,0 +1,190 @@
+package github
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	auditHttp "iq-scm-audit/http"
+	"iq-scm-audit/scm"
+	"sort"
+	"strings"
+)
+
+const teamsEndpoint = repositoryEndpoint + "/teams?per_page=100&page=%v"
+const collaboratorsEndpoint = repositoryEndpoint + "/collaborators?affiliation=all&per_page=100&page=%v"
+const userEndpoint = "/users/%v"
+
+var codeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// Ranked from least to most access
+var teamPermissions = []string{"pull", "triage", "push", "maintain", "admin"}
+
+type Team struct {
+	Name string
+	Slug string
+	Permission string
+}
+
+type Collaborator struct {
+	Login string
+	Permissions struct {
+		Admin bool
+		Maintain bool
+	}
+}
+
+type User struct {
+	Login string
+	Email string
+}
+
+type FileContent struct {
+	Content string
+	Encoding string
+}
+
+// OwningTeam returns the slug of the first team owning every file in CODEOWNERS, otherwise the team with the most access
+// to the repository.
+func (client *GitHubClient) OwningTeam(repositoryNameWithOwner string) (string, error) {
+	httpClient := client.newHttpClient(client.tokenSourceFor(repositoryNameWithOwner))
+	for _, path := range codeOwnersPaths {
+		getBytes, getError := httpClient.HttpGet(client.restUrl() + fmt.Sprintf(contentsEndpoint, repositoryNameWithOwner, path))
+		if auditHttp.IsStatus(getError, 404) {
+			continue
+		}
+		if getError != nil {
+			return "", getError
+		}
+		content := new(FileContent)
+		unmarshalError := json.Unmarshal(getBytes, &content)
+		if unmarshalError != nil || content.Encoding != "base64" {
+			return "", unexpectedResponse(getBytes)
+		}
+		codeOwners, decodeError := base64.StdEncoding.DecodeString(strings.ReplaceAll(content.Content, "\n", ""))
+		if decodeError != nil {
+			return "", unexpectedResponse(getBytes)
+		}
+		team := defaultCodeOwnerTeam(string(codeOwners))
+		if len(team) > 0 {
+			return team, nil
+		}
+		break
+	}
+
+	var teams []Team
+	for page := 1; ; page++ {
+		getBytes, getError := httpClient.HttpGet(client.restUrl() + fmt.Sprintf(teamsEndpoint, repositoryNameWithOwner, page))
+		if getError != nil {
+			return "", getError
+		}
+		var pageTeams []Team
+		unmarshalError := json.Unmarshal(getBytes, &pageTeams)
+		if unmarshalError != nil {
+			return "", unexpectedResponse(getBytes)
+		}
+		teams = append(teams, pageTeams...)
+		if len(pageTeams) < 100 {
+			break
+		}
+	}
+	return mostPermittedTeam(teams), nil
+}
+
+// mostPermittedTeam returns the slug of the team with the most access, teams with the same access by slug.
+func mostPermittedTeam(teams []Team) string {
+	sort.SliceStable(teams, func(i, j int) bool {
+		if permissionRank(teams[i].Permission) != permissionRank(teams[j].Permission) {
+			return permissionRank(teams[i].Permission) > permissionRank(teams[j].Permission)
+		}
+		return teams[i].Slug < teams[j].Slug
+	})
+	if len(teams) == 0 {
+		return ""
+	}
+	return teams[0].Slug
+}
+
+// The last rule matching every file takes precedence, its owners are @organization/team-slug, @user or email addresses.
+func defaultCodeOwnerTeam(codeOwners string) string {
+	var team string
+	for _, line := range strings.Split(codeOwners, "\n") {
+		fields := strings.Fields(strings.SplitN(line, "#", 2)[0])
+		if len(fields) == 0 || (fields[0] != "*" && fields[0] != "/**") {
+			continue
+		}
+		team = ""
+		for _, owner := range fields[1:] {
+			if strings.HasPrefix(owner, "@") && strings.Contains(owner, "/") {
+				team = owner[strings.Index(owner, "/") + 1:]
+				break
+			}
+		}
+	}
+	return team
+}
+
+func permissionRank(permission string) int {
+	for rank, rankedPermission := range teamPermissions {
+		if rankedPermission == permission {
+			return rank
+		}
+	}
+	return -1
+}
+
+// Maintainers returns the collaborators with admin or maintain permission, directly or through a team.
+func (client *GitHubClient) Maintainers(repositoryNameWithOwner string) ([]scm.Member, error) {
+	httpClient := client.newHttpClient(client.tokenSourceFor(repositoryNameWithOwner))
+	var maintainers []scm.Member
+	for page := 1; ; page++ {
+		getBytes, getError := httpClient.HttpGet(client.restUrl() + fmt.Sprintf(collaboratorsEndpoint, repositoryNameWithOwner, page))
+		if getError != nil {
+			return nil, getError
+		}
+		var collaborators []Collaborator
+		unmarshalError := json.Unmarshal(getBytes, &collaborators)
+		if unmarshalError != nil {
+			return nil, unexpectedResponse(getBytes)
+		}
+		for _, collaborator := range collaborators {
+			if !collaborator.Permissions.Admin && !collaborator.Permissions.Maintain {
+				continue
+			}
+			email, emailError := client.userEmail(httpClient, collaborator.Login)
+			if emailError != nil {
+				return nil, emailError
+			}
+			maintainers = append(maintainers, scm.Member{Login: collaborator.Login, Email: email})
+		}
+		if len(collaborators) < 100 {
+			return maintainers, nil
+		}
+	}
+}
+
+func (client *GitHubClient) userEmail(httpClient *auditHttp.HttpClient, login string) (string, error) {
+	client.userEmailsLock.Lock()
+	email, found := client.userEmails[login]
+	client.userEmailsLock.Unlock()
+	if found {
+		return email, nil
+	}
+
+	getBytes, getError := httpClient.HttpGet(client.restUrl() + fmt.Sprintf(userEndpoint, login))
+	if getError != nil {
+		return "", getError
+	}
+	user := new(User)
+	unmarshalError := json.Unmarshal(getBytes, &user)
+	if unmarshalError != nil || len(user.Login) == 0 {
+		return "", unexpectedResponse(getBytes)
+	}
+
+	client.userEmailsLock.Lock()
+	defer client.userEmailsLock.Unlock()
+	if client.userEmails == nil {
+		client.userEmails = make(map[string]string)
+	}
+	client.userEmails[login] = user.Email
+	return user.Email, nil
+}
@@ -0,0 +1,43 @@
+package github
+
+import (
+	"testing"
+)
+
+func TestDefaultCodeOwnerTeam(t *testing.T) {
+	tests := []struct {
+		codeOwners string
+		team string
+	}{
+		{"* @example/platform", "platform"},
+		{"# Default owners\n*       @octocat @example/web-team # web\n/docs/ @example/docs", "web-team"},
+		{"* @example/platform\n/api/ @example/api\n* @example/backend", "backend"},
+		{"/** @example/platform", "platform"},
+		{"* @octocat docs@example.com", ""},
+		{"* @example/platform\n* @octocat", ""},
+		{"/src/ @example/platform", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if team := defaultCodeOwnerTeam(test.codeOwners); team != test.team {
+			t.Errorf("defaultCodeOwnerTeam(%q) = %q, want %q", test.codeOwners, team, test.team)
+		}
+	}
+}
+
+func TestMostPermittedTeam(t *testing.T) {
+	tests := []struct {
+		teams []Team
+		team string
+	}{
+		{[]Team{{Slug: "readers", Permission: "pull"}, {Slug: "web", Permission: "push"}, {Slug: "platform", Permission: "admin"}}, "platform"},
+		{[]Team{{Slug: "web", Permission: "maintain"}, {Slug: "api", Permission: "maintain"}}, "api"},
+		{[]Team{{Slug: "custom", Permission: "custom-role"}, {Slug: "readers", Permission: "pull"}}, "readers"},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if team := mostPermittedTeam(test.teams); team != test.team {
+			t.Errorf("mostPermittedTeam(%v) = %q, want %q", test.teams, team, test.team)
+		}
+	}
+}
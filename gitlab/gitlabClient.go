@@ -0,0 +1,376 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	auditHttp "iq-scm-audit/http"
+	"iq-scm-audit/scm"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const Provider = "gitlab"
+const CloudUrl = "https://gitlab.com"
+const apiEndpoint = "/api/v4"
+const groupProjectsEndpoint = apiEndpoint + "/groups/%v/projects?include_subgroups=true"
+const projectsEndpoint = apiEndpoint + "/projects?membership=true"
+const projectEndpoint = apiEndpoint + "/projects/%v"
+const dependenciesEndpoint = projectEndpoint + "/dependencies"
+const releasesEndpoint = projectEndpoint + "/releases"
+const packagesEndpoint = projectEndpoint + "/packages?order_by=created_at&sort=desc"
+const packageFilesEndpoint = projectEndpoint + "/packages/%v/package_files"
+const issueEndpoint = projectEndpoint + "/issues"
+const pageSize = 100
+
+type GitLabClient struct {
+	BaseUrl string
+	Token string
+	Limiter auditHttp.Limiter
+}
+
+type Project struct {
+	Id int
+	Name string
+	Path string
+	PathWithNamespace string `json:"path_with_namespace"`
+	WebUrl string `json:"web_url"`
+	SshUrlToRepo string `json:"ssh_url_to_repo"`
+	Topics []string
+	Visibility string
+	Archived bool
+}
+
+type Dependency struct {
+	Name string
+	Version string
+	PackageManager string `json:"package_manager"`
+	DependencyFilePath string `json:"dependency_file_path"`
+}
+
+type Release struct {
+	TagName string `json:"tag_name"`
+	Assets struct {
+		Links []struct {
+			Name string
+			Url string
+			DirectAssetUrl string `json:"direct_asset_url"`
+		}
+	}
+}
+
+type Package struct {
+	Id int
+	Name string
+	Version string
+	PackageType string `json:"package_type"`
+}
+
+type Issue struct {
+	WebUrl string `json:"web_url"`
+}
+
+type PackageFile struct {
+	FileName string `json:"file_name"`
+}
+
+func NewGitLabClient(baseUrl string, token string) *GitLabClient {
+	var gitLabClient = new(GitLabClient)
+	gitLabClient.BaseUrl = strings.TrimSuffix(baseUrl, "/")
+	gitLabClient.Token = token
+	return gitLabClient
+}
+
+func (client *GitLabClient) Provider() string {
+	return Provider
+}
+
+func (client *GitLabClient) ServerUrl() string {
+	if client.BaseUrl == CloudUrl {
+		return ""
+	}
+	return client.BaseUrl
+}
+
+// Queries follow the GitHub search style: "group:<full path>" lists every project in a group and its
+// subgroups, "<namespace>/<project>" selects a single project and any other text is a project search.
+func (client *GitLabClient) GetRepositories(query string) ([]scm.Repository, error) {
+	var group string
+	var search []string
+	for _, term := range strings.Fields(query) {
+		if strings.HasPrefix(term, "group:") {
+			group = strings.TrimPrefix(term, "group:")
+		} else {
+			search = append(search, term)
+		}
+	}
+
+	var projects []Project
+	if len(group) == 0 && len(search) == 1 && strings.Contains(search[0], "/") {
+		project, projectError := client.getProject(search[0])
+		if projectError != nil {
+			return nil, projectError
+		}
+		projects = append(projects, *project)
+	} else {
+		endpoint := projectsEndpoint
+		if len(group) > 0 {
+			endpoint = fmt.Sprintf(groupProjectsEndpoint, url.PathEscape(group))
+		}
+		if len(search) > 0 {
+			endpoint += "&search=" + url.QueryEscape(strings.Join(search, " "))
+		}
+		listError := client.getAll(endpoint, func(pageBytes []byte) (int, error) {
+			var page []Project
+			pageError := json.Unmarshal(pageBytes, &page)
+			if pageError != nil {
+				return 0, unexpectedResponse(pageBytes)
+			}
+			projects = append(projects, page...)
+			return len(page), nil
+		})
+		if listError != nil {
+			return nil, listError
+		}
+	}
+
+	var repositories []scm.Repository
+	for _, project := range projects {
+		log.Println("Getting GitLab Project - " + project.PathWithNamespace)
+		repository := new(scm.Repository)
+		// The path, unlike the display name, is unique within the namespace and safe as an IQ public id
+		repository.Name = project.Path
+		repository.NameWithOwner = project.PathWithNamespace
+		repository.Url = project.WebUrl
+		repository.SshUrl = project.SshUrlToRepo
+		repository.Topics = project.Topics
+		repository.Visibility = project.Visibility
+		repository.Archived = project.Archived
+		manifests, manifestsError := client.getManifests(project.Id)
+		releaseAssets, releaseError := client.getLatestReleaseAssets(project.Id)
+		packageFiles, packagesError := client.getLatestPackageFiles(project.Id)
+		for _, projectError := range []error{manifestsError, releaseError, packagesError} {
+			if projectError != nil && repository.Error == nil {
+				repository.Error = projectError
+			}
+		}
+		repository.Manifests = manifests
+		repository.ReleaseAssets = releaseAssets
+		repository.PackageFiles = packageFiles
+		repositories = append(repositories, *repository)
+	}
+	return repositories, nil
+}
+
+func (client *GitLabClient) CreateIssue(repositoryNameWithOwner string, title string, markdown string) (string, error) {
+	postBytes, postError := client.getHttpClient().HttpPost(client.apiUrl(issueEndpoint, url.PathEscape(repositoryNameWithOwner)), map[string]string {
+		"title": title,
+		"description": markdown,
+	})
+	if postError != nil {
+		return "", postError
+	}
+	issue := new(Issue)
+	unmarshalError := json.Unmarshal(postBytes, &issue)
+	if unmarshalError != nil || len(issue.WebUrl) == 0 {
+		return "", unexpectedResponse(postBytes)
+	}
+	return issue.WebUrl, nil
+}
+
+func (client *GitLabClient) DownloadRelease(assetUrl string) ([]byte, error) {
+	// Release links may point outside of GitLab, only send the token to the GitLab host
+	if strings.HasPrefix(assetUrl, client.BaseUrl + "/") {
+		return client.getHttpClient().HttpGet(assetUrl)
+	}
+	httpClient := new(auditHttp.HttpClient)
+	httpClient.Limiter = client.Limiter
+	return httpClient.HttpGet(assetUrl)
+}
+
+func (client *GitLabClient) getProject(pathWithNamespace string) (*Project, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.apiUrl(projectEndpoint, url.PathEscape(pathWithNamespace)))
+	if getError != nil {
+		return nil, getError
+	}
+	project := new(Project)
+	unmarshalError := json.Unmarshal(getBytes, &project)
+	if unmarshalError != nil || project.Id == 0 {
+		return nil, unexpectedResponse(getBytes)
+	}
+	return project, nil
+}
+
+func (client *GitLabClient) getManifests(projectId int) ([]scm.Manifest, error) {
+	var dependencies []Dependency
+	dependenciesError := client.getAll(client.apiPath(dependenciesEndpoint, projectId), func(pageBytes []byte) (int, error) {
+		var page []Dependency
+		pageError := json.Unmarshal(pageBytes, &page)
+		if pageError != nil {
+			return 0, unexpectedResponse(pageBytes)
+		}
+		dependencies = append(dependencies, page...)
+		return len(page), nil
+	})
+	// GitLab returns 404 if dependency scanning is not available for the project and 403 below the Ultimate tier
+	if auditHttp.IsStatus(dependenciesError, http.StatusNotFound) || auditHttp.IsStatus(dependenciesError, http.StatusForbidden) {
+		return nil, nil
+	}
+	if dependenciesError != nil {
+		return nil, dependenciesError
+	}
+
+	var manifests []scm.Manifest
+	manifestIndexes := make(map[string]int)
+	for _, dependency := range dependencies {
+		index, found := manifestIndexes[dependency.DependencyFilePath]
+		if !found {
+			index = len(manifests)
+			manifestIndexes[dependency.DependencyFilePath] = index
+			manifests = append(manifests, scm.Manifest{Filename: dependency.DependencyFilePath})
+		}
+		manifests[index].Dependencies = append(manifests[index].Dependencies, dependency.toScmDependency())
+	}
+	return manifests, nil
+}
+
+func (client *GitLabClient) getLatestReleaseAssets(projectId int) ([]scm.Asset, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.apiUrl(releasesEndpoint, projectId) + "?per_page=1")
+	if getError != nil {
+		return nil, getError
+	}
+	var releases []Release
+	unmarshalError := json.Unmarshal(getBytes, &releases)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
+	}
+	var assets []scm.Asset
+	if len(releases) > 0 {
+		for _, link := range releases[0].Assets.Links {
+			assetUrl := link.DirectAssetUrl
+			if len(assetUrl) == 0 {
+				assetUrl = link.Url
+			}
+			assets = append(assets, scm.Asset{Name: link.Name, Url: assetUrl})
+		}
+	}
+	return assets, nil
+}
+
+func (client *GitLabClient) getLatestPackageFiles(projectId int) ([]scm.Asset, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.apiUrl(packagesEndpoint, projectId) + "&per_page=1")
+	// GitLab returns 404 if the package registry is disabled for the project and 403 if it is not accessible
+	if auditHttp.IsStatus(getError, http.StatusNotFound) || auditHttp.IsStatus(getError, http.StatusForbidden) {
+		return nil, nil
+	}
+	if getError != nil {
+		return nil, getError
+	}
+	var packages []Package
+	unmarshalError := json.Unmarshal(getBytes, &packages)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
+	}
+	if len(packages) == 0 {
+		return nil, nil
+	}
+
+	pkg := packages[0]
+	var files []scm.Asset
+	filesError := client.getAll(client.apiPath(packageFilesEndpoint, projectId, pkg.Id), func(pageBytes []byte) (int, error) {
+		var page []PackageFile
+		pageError := json.Unmarshal(pageBytes, &page)
+		if pageError != nil {
+			return 0, unexpectedResponse(pageBytes)
+		}
+		for _, file := range page {
+			fileUrl := client.packageFileUrl(projectId, pkg, file.FileName)
+			if len(fileUrl) > 0 {
+				files = append(files, scm.Asset{Name: file.FileName, Url: fileUrl})
+			}
+		}
+		return len(page), nil
+	})
+	if filesError != nil {
+		return nil, filesError
+	}
+	return files, nil
+}
+
+func (client *GitLabClient) packageFileUrl(projectId int, pkg Package, fileName string) string {
+	projectUrl := client.apiUrl(projectEndpoint, projectId)
+	switch pkg.PackageType {
+	case "generic":
+		return projectUrl + "/packages/generic/" + url.PathEscape(pkg.Name) + "/" + url.PathEscape(pkg.Version) + "/" + url.PathEscape(fileName)
+	case "maven":
+		return projectUrl + "/packages/maven/" + pkg.Name + "/" + url.PathEscape(pkg.Version) + "/" + url.PathEscape(fileName)
+	case "npm":
+		return projectUrl + "/packages/npm/" + pkg.Name + "/-/" + url.PathEscape(fileName)
+	}
+	log.Println("Unsupported GitLab package type, Skipping - " + pkg.Name + ":" + pkg.PackageType)
+	return ""
+}
+
+func (client *GitLabClient) getAll(endpoint string, appendPage func(pageBytes []byte) (int, error)) error {
+	separator := "?"
+	if strings.Contains(endpoint, "?") {
+		separator = "&"
+	}
+	for page := 1; ; page++ {
+		pageUrl := client.BaseUrl + endpoint + separator + fmt.Sprintf("per_page=%v&page=%v", pageSize, page)
+		pageBytes, getError := client.getHttpClient().HttpGet(pageUrl)
+		if getError != nil {
+			return getError
+		}
+		count, appendError := appendPage(pageBytes)
+		if appendError != nil {
+			return appendError
+		}
+		if count < pageSize {
+			return nil
+		}
+	}
+}
+
+func unexpectedResponse(responseBytes []byte) error {
+	return errors.New("unexpected response from GitLab - " + string(responseBytes))
+}
+
+func (client *GitLabClient) apiPath(endpoint string, arguments ...interface{}) string {
+	return fmt.Sprintf(endpoint, arguments...)
+}
+
+func (client *GitLabClient) apiUrl(endpoint string, arguments ...interface{}) string {
+	return client.BaseUrl + client.apiPath(endpoint, arguments...)
+}
+
+func (client *GitLabClient) getHttpClient() *auditHttp.HttpClient {
+	httpClient := new(auditHttp.HttpClient)
+	httpClient.Token = client.Token
+	httpClient.Limiter = client.Limiter
+	return httpClient
+}
+
+func (dependency *Dependency) toScmDependency() scm.Dependency {
+	packageManager := strings.ToLower(dependency.PackageManager)
+	packageName := dependency.Name
+	switch packageManager {
+	case "maven", "gradle", "sbt":
+		packageManager = "maven"
+		packageName = strings.Replace(packageName, "/", ":", 1)
+	case "yarn", "pnpm":
+		packageManager = "npm"
+	case "bundler":
+		packageManager = "rubygems"
+	case "pipenv", "poetry", "setuptools", "conda":
+		packageManager = "pip"
+	}
+	// Match the GitHub dependency graph requirement format of an exact version
+	return scm.Dependency{
+		PackageManager: packageManager,
+		PackageName: packageName,
+		Requirements: "= " + dependency.Version,
+	}
+}
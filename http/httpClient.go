@@ -2,13 +2,12 @@ package http
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"golang.org/x/oauth2"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 )
 
@@ -16,44 +15,104 @@ type HttpClient struct {
 	Username string
 	Password string
 	Token string
+	TokenSource oauth2.TokenSource
+	Limiter Limiter
 }
 
-func (client *HttpClient) HttpGet(url string) []byte {
-	return client.httpRequest("GET", "application/json", nil, url)
+type HttpError struct {
+	Verb string
+	Url string
+	StatusCode int
+	Body string
 }
 
-func (client *HttpClient) HttpPost(url string, body interface{}) []byte {
+func (httpError *HttpError) Error() string {
+	return fmt.Sprintf("%v %v returned %v - %v", httpError.Verb, httpError.Url, httpError.StatusCode, httpError.Body)
+}
+
+func IsStatus(err error, statusCode int) bool {
+	httpError, isHttpError := err.(*HttpError)
+	return isHttpError && httpError.StatusCode == statusCode
+}
+
+func (client *HttpClient) HttpGet(url string) ([]byte, error) {
+	return client.httpRequest("GET", "", "application/json", nil, url)
+}
+
+// HttpDownload gets a file, such as a GitHub release asset, from an API that returns its content for
+// application/octet-stream.
+func (client *HttpClient) HttpDownload(url string) ([]byte, error) {
+	return client.httpRequest("GET", "application/octet-stream", "application/json", nil, url)
+}
+
+func (client *HttpClient) HttpPost(url string, body interface{}) ([]byte, error) {
 	jsonBytes, unmarshallError := json.Marshal(body)
 
 	if unmarshallError != nil {
-		log.Fatal(unmarshallError)
+		return nil, unmarshallError
 	}
 
-	return client.httpRequest("POST", "application/json", bytes.NewBuffer(jsonBytes), url)
+	return client.httpRequest("POST", "", "application/json", bytes.NewBuffer(jsonBytes), url)
 }
 
-func (client *HttpClient) HttpPostXml(url string, body interface{}) []byte {
+func (client *HttpClient) HttpPatch(url string, body interface{}) ([]byte, error) {
+	jsonBytes, unmarshallError := json.Marshal(body)
+	if unmarshallError != nil {
+		return nil, unmarshallError
+	}
+	return client.httpRequest("PATCH", "", "application/json", bytes.NewBuffer(jsonBytes), url)
+}
+
+func (client *HttpClient) HttpPut(url string, body interface{}) ([]byte, error) {
+	jsonBytes, unmarshallError := json.Marshal(body)
+	if unmarshallError != nil {
+		return nil, unmarshallError
+	}
+	return client.httpRequest("PUT", "", "application/json", bytes.NewBuffer(jsonBytes), url)
+}
+
+func (client *HttpClient) HttpPostXml(url string, body interface{}) ([]byte, error) {
 	xmlBytes, unmarshallError := xml.Marshal(body)
 	if unmarshallError != nil {
-		log.Fatal(unmarshallError)
+		return nil, unmarshallError
 	}
-	return client.httpRequest("POST", "application/xml", bytes.NewBuffer(xmlBytes), url)
+	return client.httpRequest("POST", "", "application/xml", bytes.NewBuffer(xmlBytes), url)
 }
 
-func (client *HttpClient) httpRequest(verb string, contentType string, body io.Reader, url string) []byte {
-	var httpClient *http.Client
-	if len(client.Token) > 0 {
-		src := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: client.Token},
-		)
-		httpClient = oauth2.NewClient(context.Background(), src)
-	} else {
-		httpClient = &http.Client {}
+// HttpPostContent posts a body that is already encoded.
+func (client *HttpClient) HttpPostContent(url string, contentType string, content []byte) ([]byte, error) {
+	return client.httpRequest("POST", "", contentType, bytes.NewBuffer(content), url)
+}
+
+func (client *HttpClient) HttpPostJsonPatch(url string, body interface{}) ([]byte, error) {
+	jsonBytes, unmarshallError := json.Marshal(body)
+	if unmarshallError != nil {
+		return nil, unmarshallError
 	}
+	return client.httpRequest("POST", "", "application/json-patch+json", bytes.NewBuffer(jsonBytes), url)
+}
+
+// The token is set on the request rather than by an oauth2 transport, so redirects to another host, such as signed
+// download Urls, do not receive it.
+func (client *HttpClient) httpRequest(verb string, accept string, contentType string, body io.Reader, url string) ([]byte, error) {
+	var httpClient = &http.Client {}
 	request, requestError := http.NewRequest(verb, url, body)
 
 	if requestError != nil {
-		log.Fatal(requestError)
+		return nil, requestError
+	}
+
+	if client.TokenSource != nil {
+		token, tokenError := client.TokenSource.Token()
+		if tokenError != nil {
+			return nil, tokenError
+		}
+		token.SetAuthHeader(request)
+	} else if len(client.Token) > 0 {
+		(&oauth2.Token{AccessToken: client.Token}).SetAuthHeader(request)
+	}
+	if len(accept) > 0 {
+		request.Header.Set("Accept", accept)
 	}
 
 	if len(client.Username) > 0 && len(client.Password) > 0 {
@@ -61,10 +120,12 @@ func (client *HttpClient) httpRequest(verb string, contentType string, body io.R
 	}
 	request.Header.Set("Content-Type", contentType)
 
+	client.Limiter.Acquire()
+	defer client.Limiter.Release()
 	response, requestError := httpClient.Do(request)
 
 	if requestError != nil {
-		log.Fatal(requestError)
+		return nil, requestError
 	}
 
 	defer response.Body.Close()
@@ -72,8 +133,12 @@ func (client *HttpClient) httpRequest(verb string, contentType string, body io.R
 	responseBytes, requestError := ioutil.ReadAll(response.Body)
 
 	if requestError != nil {
-		log.Fatal(requestError)
+		return nil, requestError
+	}
+
+	if response.StatusCode >= 400 {
+		return responseBytes, &HttpError{Verb: verb, Url: url, StatusCode: response.StatusCode, Body: string(responseBytes)}
 	}
 
-	return responseBytes
+	return responseBytes, nil
 }
@@ -0,0 +1,23 @@
+package http
+
+// Limiter bounds how many requests run at once across every client it is shared with, a nil Limiter is unlimited.
+type Limiter chan struct{}
+
+func NewLimiter(limit int) Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	return make(Limiter, limit)
+}
+
+func (limiter Limiter) Acquire() {
+	if limiter != nil {
+		limiter <- struct{}{}
+	}
+}
+
+func (limiter Limiter) Release() {
+	if limiter != nil {
+		<-limiter
+	}
+}
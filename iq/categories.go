@@ -0,0 +1,74 @@
+package iq
+
+import (
+	"encoding/json"
+	"net/url"
+)
+
+const applicationCategoriesEndpoint = apiEndpoint + "applicationCategories/application/"
+
+type ApplicationCategory struct {
+	Id string
+	Name string
+}
+
+type ApplicationTag struct {
+	TagId string `json:"tagId"`
+}
+
+// GetApplicationCategories returns the categories that can be applied to an application, those of its organization and
+// the organizations above it.
+func (client *IqClient) GetApplicationCategories(publicId string) ([]ApplicationCategory, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.IqServerUrl + applicationCategoriesEndpoint + url.PathEscape(publicId))
+	if getError != nil {
+		return nil, getError
+	}
+	var categories []ApplicationCategory
+	unmarshalError := json.Unmarshal(getBytes, &categories)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
+	}
+	return categories, nil
+}
+
+// GetApplicationTagIds returns the ids of the categories applied to an application.
+func (client *IqClient) GetApplicationTagIds(applicationId string) ([]string, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.IqServerUrl + applicationsEndpoint + applicationId)
+	if getError != nil {
+		return nil, getError
+	}
+	application := new(struct {
+		Id string
+		ApplicationTags []ApplicationTag
+	})
+	unmarshalError := json.Unmarshal(getBytes, &application)
+	if unmarshalError != nil || len(application.Id) == 0 {
+		return nil, unexpectedResponse(getBytes)
+	}
+	var tagIds []string
+	for _, tag := range application.ApplicationTags {
+		tagIds = append(tagIds, tag.TagId)
+	}
+	return tagIds, nil
+}
+
+// SetApplicationTagIds replaces the categories applied to an application, the rest of the application is left as is.
+func (client *IqClient) SetApplicationTagIds(applicationId string, tagIds []string) error {
+	httpClient := client.getHttpClient()
+	getBytes, getError := httpClient.HttpGet(client.IqServerUrl + applicationsEndpoint + applicationId)
+	if getError != nil {
+		return getError
+	}
+	var application map[string]interface{}
+	unmarshalError := json.Unmarshal(getBytes, &application)
+	if unmarshalError != nil {
+		return unexpectedResponse(getBytes)
+	}
+	tags := []ApplicationTag{}
+	for _, tagId := range tagIds {
+		tags = append(tags, ApplicationTag{TagId: tagId})
+	}
+	application["applicationTags"] = tags
+	_, putError := httpClient.HttpPut(client.IqServerUrl + applicationsEndpoint + applicationId, application)
+	return putError
+}
@@ -0,0 +1,182 @@
+package iq
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	auditHttp "iq-scm-audit/http"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const versionEndpoint = "/rest/product/version"
+const DefaultCliDownloadUrl = "https://download.sonatype.com/clm/scanner/nexus-iq-cli-{version}.jar"
+
+// Matches the version java -version prints, 1.8.0_292 for Java 8 and 11.0.2 or 17 from Java 9 on
+var javaVersionPattern = regexp.MustCompile(`version "(\d+)(?:\.(\d+))?`)
+
+// Keys of JVM system properties whose values are redacted, such as -Dhttps.proxyPassword
+var secretPropertyPattern = regexp.MustCompile(`(?i)^-D[^=]*(password|secret|token)[^=]*=(.+)$`)
+
+type ProductVersion struct {
+	Version string
+}
+
+func (client *IqClient) GetServerVersion() (string, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.IqServerUrl + versionEndpoint)
+	if getError != nil {
+		return "", getError
+	}
+	productVersion := new(ProductVersion)
+	unmarshalError := json.Unmarshal(getBytes, &productVersion)
+	if unmarshalError != nil || len(productVersion.Version) == 0 {
+		return "", unexpectedResponse(getBytes)
+	}
+	return productVersion.Version, nil
+}
+
+// DownloadCli fetches the Nexus IQ CLI matching the IQ Server version into cacheDirectory, unless it is already cached,
+// and evaluates with it from then on. {version} in downloadUrl is replaced by the IQ Server version, so the Url can point
+// at a local mirror. The jar must match expectedSha256, or the SHA-256 published next to it when not supplied.
+func (client *IqClient) DownloadCli(downloadUrl string, cacheDirectory string, expectedSha256 string) error {
+	version, versionError := client.GetServerVersion()
+	if versionError != nil {
+		return versionError
+	}
+	jarUrl := strings.Replace(downloadUrl, "{version}", version, -1)
+	jarPath := filepath.Join(cacheDirectory, "nexus-iq-cli-" + version + ".jar")
+
+	downloadClient := new(auditHttp.HttpClient)
+	if len(expectedSha256) == 0 {
+		checksumBytes, checksumError := downloadClient.HttpGet(jarUrl + ".sha256")
+		if checksumError != nil {
+			return errors.New("unable to get the Nexus IQ CLI checksum, supply it instead - " + checksumError.Error())
+		}
+		// Checksum files may be followed by the file name
+		fields := strings.Fields(string(checksumBytes))
+		if len(fields) == 0 {
+			return errors.New("empty Nexus IQ CLI checksum - " + jarUrl + ".sha256")
+		}
+		expectedSha256 = fields[0]
+		log.Println("Checking the Nexus IQ CLI against the checksum published next to it, supply iqCliSha256 to pin it - " + jarUrl + ".sha256")
+	}
+
+	cachedBytes, readError := ioutil.ReadFile(jarPath)
+	if readError == nil && matchesSha256(cachedBytes, expectedSha256) {
+		log.Println("Using cached Nexus IQ CLI - " + jarPath)
+		client.CliJar = jarPath
+		return nil
+	}
+
+	log.Println("Downloading Nexus IQ CLI - " + jarUrl)
+	jarBytes, downloadError := downloadClient.HttpGet(jarUrl)
+	if downloadError != nil {
+		return downloadError
+	}
+	if !matchesSha256(jarBytes, expectedSha256) {
+		return errors.New("Nexus IQ CLI checksum does not match - " + jarUrl)
+	}
+	directoryError := os.MkdirAll(cacheDirectory, 0700)
+	if directoryError != nil {
+		return directoryError
+	}
+	writeError := ioutil.WriteFile(jarPath + ".tmp", jarBytes, 0600)
+	if writeError != nil {
+		return writeError
+	}
+	renameError := os.Rename(jarPath + ".tmp", jarPath)
+	if renameError != nil {
+		return renameError
+	}
+	client.CliJar = jarPath
+	return nil
+}
+
+// writeArgumentFile writes java launcher arguments to a temporary file created with 0600 permissions, java reads them
+// from "@<file>" and the caller removes the file once the process exits.
+func writeArgumentFile(arguments []string) (string, error) {
+	argumentFile, createError := ioutil.TempFile("", "iq-cli-*.args")
+	if createError != nil {
+		return "", createError
+	}
+	var lines []string
+	for _, argument := range arguments {
+		// Quoted so arguments containing spaces stay whole, backslashes and quotes are escaped inside the quotes
+		lines = append(lines, "\"" + strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(argument) + "\"")
+	}
+	_, writeError := argumentFile.WriteString(strings.Join(lines, "\n") + "\n")
+	closeError := argumentFile.Close()
+	if writeError == nil {
+		writeError = closeError
+	}
+	if writeError != nil {
+		_ = os.Remove(argumentFile.Name())
+		return "", writeError
+	}
+	return argumentFile.Name(), nil
+}
+
+// supportsArgumentFiles reports whether the java launcher reads "@<file>" argument files, which came with Java 9. The
+// version is read once, when it cannot be read argument files are assumed so credentials stay off the command line.
+func (client *IqClient) supportsArgumentFiles() bool {
+	client.javaVersionOnce.Do(func() {
+		versionBytes, versionError := exec.Command(client.JavaExecutable, "-version").CombinedOutput()
+		if versionError != nil {
+			return
+		}
+		client.javaVersion = parseJavaMajorVersion(string(versionBytes))
+		if client.javaVersion > 0 && client.javaVersion < 9 && client.AllowCommandLineCredentials {
+			log.Println(fmt.Sprintf("Java %v does not support argument files, the Nexus IQ CLI credentials are visible in the process list - %v", client.javaVersion, client.JavaExecutable))
+		}
+	})
+	return client.javaVersion == 0 || client.javaVersion >= 9
+}
+
+// parseJavaMajorVersion returns the major version in java -version output, 8 for 1.8.0_292, or 0 when there is none.
+func parseJavaMajorVersion(output string) int {
+	match := javaVersionPattern.FindStringSubmatch(output)
+	if match == nil {
+		return 0
+	}
+	major, _ := strconv.Atoi(match[1])
+	if major == 1 && len(match[2]) > 0 {
+		major, _ = strconv.Atoi(match[2])
+	}
+	return major
+}
+
+// redact removes the IQ password and the secret JVM system properties, such as a proxy password, from Nexus IQ CLI
+// output before it is logged or returned in an error.
+func (client *IqClient) redact(output string) string {
+	for _, secret := range client.secrets() {
+		output = strings.Replace(output, secret, "********", -1)
+	}
+	return output
+}
+
+func (client *IqClient) secrets() []string {
+	var secrets []string
+	if len(client.Password) > 0 {
+		secrets = append(secrets, client.Password)
+	}
+	for _, option := range client.JvmOptions {
+		match := secretPropertyPattern.FindStringSubmatch(option)
+		if match != nil {
+			secrets = append(secrets, match[2])
+		}
+	}
+	return secrets
+}
+
+func matchesSha256(content []byte, expectedSha256 string) bool {
+	hash := sha256.Sum256(content)
+	return strings.EqualFold(hex.EncodeToString(hash[:]), strings.TrimSpace(expectedSha256))
+}
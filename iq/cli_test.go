@@ -0,0 +1,34 @@
+package iq
+
+import (
+	"testing"
+)
+
+func TestParseJavaMajorVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		version int
+	}{
+		{"java version \"1.8.0_292\"\nJava(TM) SE Runtime Environment (build 1.8.0_292-b10)", 8},
+		{"openjdk version \"11.0.2\" 2019-01-15", 11},
+		{"openjdk version \"17\" 2021-09-14", 17},
+		{"openjdk version \"9-ea\"", 9},
+		{"command not found", 0},
+	}
+	for _, test := range tests {
+		version := parseJavaMajorVersion(test.output)
+		if version != test.version {
+			t.Errorf("parseJavaMajorVersion(%q) = %v, want %v", test.output, version, test.version)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	client := NewIqClient("http://localhost:8070", "admin", "s3cret")
+	client.JvmOptions = []string{"-Xmx2g", "-Dhttps.proxyHost=proxy", "-Dhttps.proxyPassword=pr0xy", "-Dapi.token=t0ken"}
+	output := client.redact("-a admin:s3cret -Dhttps.proxyPassword=pr0xy -Dapi.token=t0ken -Dhttps.proxyHost=proxy")
+	want := "-a admin:******** -Dhttps.proxyPassword=******** -Dapi.token=******** -Dhttps.proxyHost=proxy"
+	if output != want {
+		t.Errorf("redact() = %q, want %q", output, want)
+	}
+}
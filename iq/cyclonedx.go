@@ -0,0 +1,61 @@
+package iq
+
+import (
+	"errors"
+	"iq-scm-audit/sbom"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// The first IQ Server release accepting each CycloneDX spec version, 1.1 is accepted by every release
+var cycloneDxReleases = []struct {
+	Release int
+	SpecVersion string
+}{
+	{97, "1.2"},
+	{118, "1.3"},
+	{140, "1.4"},
+	{165, "1.5"},
+}
+
+// GetSbomSpecVersion returns SbomSpecVersion when set, otherwise the newest CycloneDX spec version the IQ Server
+// release accepts. The IQ Server version is only requested once.
+func (client *IqClient) GetSbomSpecVersion() (string, error) {
+	client.sbomSpecVersionOnce.Do(func() {
+		if len(client.SbomSpecVersion) > 0 {
+			if !sbom.IsSpecVersion(client.SbomSpecVersion) {
+				client.sbomSpecVersionError = errors.New("unsupported CycloneDX spec version, use one of " +
+					strings.Join(sbom.SpecVersions, ", ") + " - " + client.SbomSpecVersion)
+			}
+			return
+		}
+		version, versionError := client.GetServerVersion()
+		if versionError != nil {
+			client.sbomSpecVersionError = versionError
+			return
+		}
+		client.SbomSpecVersion = cycloneDxSpecVersion(version)
+		log.Println("Scanning CycloneDX " + client.SbomSpecVersion + " SBOMs with IQ Server " + version)
+	})
+	return client.SbomSpecVersion, client.sbomSpecVersionError
+}
+
+// cycloneDxSpecVersion reads the release from an IQ Server version such as 1.165.0-01.
+func cycloneDxSpecVersion(serverVersion string) string {
+	specVersion := sbom.SpecVersions[0]
+	parts := strings.Split(serverVersion, ".")
+	if len(parts) < 2 {
+		return specVersion
+	}
+	release, releaseError := strconv.Atoi(parts[1])
+	if releaseError != nil {
+		return specVersion
+	}
+	for _, cycloneDxRelease := range cycloneDxReleases {
+		if release >= cycloneDxRelease.Release {
+			specVersion = cycloneDxRelease.SpecVersion
+		}
+	}
+	return specVersion
+}
@@ -3,13 +3,18 @@ package iq
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	auditHttp "iq-scm-audit/http"
 	"iq-scm-audit/sbom"
 	"log"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -19,11 +24,27 @@ const applicationScmEndpoint = apiEndpoint + "sourceControl/application/"
 const organizationsEndpoint = apiEndpoint + "organizations/"
 const organizationScmEndpoint = apiEndpoint + "sourceControl/organization/"
 const scanEndpoint = apiEndpoint + "scan/applications/"
+const DefaultCliJar = "./iq/nexus-iq-cli-1.78.0-02.jar"
 
 type IqClient struct {
 	IqServerUrl string
 	Username string
 	Password string
+	CliJar string
+	JavaExecutable string
+	JvmOptions []string
+	// Java 8 has no argument files, the credentials are only put on its command line when explicitly allowed
+	AllowCommandLineCredentials bool
+	// Shared by every worker of a concurrent audit to bound the IQ API requests and Nexus IQ CLI evaluations in flight
+	Limiter auditHttp.Limiter
+	EvaluationLimiter auditHttp.Limiter
+	// CycloneDX spec version of scanned SBOMs, the newest the IQ Server accepts when empty
+	SbomSpecVersion string
+	SbomFormat string
+	sbomSpecVersionOnce sync.Once
+	sbomSpecVersionError error
+	javaVersionOnce sync.Once
+	javaVersion int
 }
 
 type Applications struct {
@@ -43,6 +64,11 @@ type ApplicationScm struct {
 	RepositoryUrl string
 }
 
+type OrganizationScm struct {
+	Provider string
+	BaseUrl string
+}
+
 type Organizations struct {
 	Organizations[] Organization
 }
@@ -50,6 +76,7 @@ type Organizations struct {
 type Organization struct {
 	Id string
 	Name string
+	ParentOrganizationId string
 }
 
 type SbomScanTicket struct {
@@ -71,168 +98,301 @@ func NewIqClient(iqServerUrl string, username string, password string) *IqClient
 	iqClient.IqServerUrl = iqServerUrl
 	iqClient.Username = username
 	iqClient.Password = password
+	iqClient.CliJar = DefaultCliJar
+	iqClient.JavaExecutable = "java"
 	return iqClient
 }
 
-func (client *IqClient) GetApplications() *Applications {
-	var getBytes = client.getHttpClient().HttpGet(client.IqServerUrl + applicationsEndpoint)
-	var applications = new(Applications)
-	getError := json.Unmarshal(getBytes, &applications)
+func (client *IqClient) GetApplications() (*Applications, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.IqServerUrl + applicationsEndpoint)
 	if getError != nil {
-		log.Fatal(string(getBytes))
+		return nil, getError
+	}
+	var applications = new(Applications)
+	unmarshalError := json.Unmarshal(getBytes, &applications)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
 	}
 	for index := range applications.Applications {
 		application := &applications.Applications[index]
-		var repositoryUrl = client.GetApplicationScm(application.Id).RepositoryUrl
-		application.RepositoryUrl = repositoryUrl
+		applicationScm, scmError := client.GetApplicationScm(application.Id)
+		if scmError != nil {
+			return nil, scmError
+		}
+		application.RepositoryUrl = applicationScm.RepositoryUrl
 	}
-	return applications
+	return applications, nil
 }
 
-func (client *IqClient) GetOrCreateOrganization(organizationName string) *Organization {
-	getBytes := client.getHttpClient().HttpGet(client.IqServerUrl + organizationsEndpoint)
-	organizations := new(Organizations)
-	getError := json.Unmarshal(getBytes, &organizations)
+// GetOrganization finds an organization by name, among the children of parentOrganizationId when it is not empty.
+func (client *IqClient) GetOrganization(organizationName string, parentOrganizationId string) (*Organization, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.IqServerUrl + organizationsEndpoint)
 	if getError != nil {
-		log.Fatal(string(getBytes))
+		return nil, getError
+	}
+	organizations := new(Organizations)
+	unmarshalError := json.Unmarshal(getBytes, &organizations)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
 	}
 	for _, organization := range organizations.Organizations {
-		if organization.Name == organizationName {
+		if organization.Name == organizationName && (len(parentOrganizationId) == 0 || organization.ParentOrganizationId == parentOrganizationId) {
 			log.Println("Found existing organization - " + organization.Name + ":" + organization.Id)
-			return &organization
+			return &organization, nil
 		}
 	}
+	return nil, nil
+}
+
+// GetOrCreateOrganization creates a missing organization under parentOrganizationId, or the Root Organization when it is
+// empty.
+func (client *IqClient) GetOrCreateOrganization(organizationName string, parentOrganizationId string) (*Organization, error) {
+	existingOrganization, getError := client.GetOrganization(organizationName, parentOrganizationId)
+	if getError != nil || existingOrganization != nil {
+		return existingOrganization, getError
+	}
 
-	var postBytes = client.getHttpClient().HttpPost(client.IqServerUrl + organizationsEndpoint, map[string]string {
+	organization := map[string]string {
 		"name": organizationName,
-	})
-	organization := new(Organization)
-	postError := json.Unmarshal(postBytes, &organization)
+	}
+	if len(parentOrganizationId) > 0 {
+		organization["parentOrganizationId"] = parentOrganizationId
+	}
+	postBytes, postError := client.getHttpClient().HttpPost(client.IqServerUrl + organizationsEndpoint, organization)
 	if postError != nil {
-		log.Fatal(string(postBytes))
+		return nil, postError
+	}
+	createdOrganization := new(Organization)
+	unmarshalError := json.Unmarshal(postBytes, &createdOrganization)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(postBytes)
 	}
-	return organization
+	return createdOrganization, nil
 }
 
-func (client *IqClient) GetOrCreateApplication(organizationId string, publicId string, name string) *Application {
-	getBytes := client.getHttpClient().HttpGet(client.IqServerUrl + applicationsEndpoint + "?publicId=" + publicId)
-	applications := new(Applications)
-	var application Application
-	getError := json.Unmarshal(getBytes, &applications)
+func (client *IqClient) GetApplication(publicId string) (*Application, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.IqServerUrl + applicationsEndpoint + "?publicId=" + url.QueryEscape(publicId))
 	if getError != nil {
-		log.Fatal(string(getBytes))
+		return nil, getError
+	}
+	applications := new(Applications)
+	unmarshalError := json.Unmarshal(getBytes, &applications)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
 	}
 	if len(applications.Applications) > 0 {
-		application = applications.Applications[0]
+		application := applications.Applications[0]
 		log.Println("Found existing application - " + application.Name + ":" + application.PublicId)
-		return &application
+		return &application, nil
+	}
+	return nil, nil
+}
+
+func (client *IqClient) GetOrCreateApplication(organizationId string, publicId string, name string) (*Application, bool, error) {
+	existingApplication, getError := client.GetApplication(publicId)
+	if getError != nil || existingApplication != nil {
+		return existingApplication, false, getError
 	}
 
-	postBytes := client.getHttpClient().HttpPost(client.IqServerUrl + applicationsEndpoint, map[string]string {
+	postBytes, postError := client.getHttpClient().HttpPost(client.IqServerUrl + applicationsEndpoint, map[string]string {
 		"publicId": publicId,
 		"name": name,
 		"organizationId": organizationId,
 	})
-	postError := json.Unmarshal(postBytes, &application)
 	if postError != nil {
-		log.Fatal(string(postBytes))
+		return nil, false, postError
+	}
+	application := new(Application)
+	unmarshalError := json.Unmarshal(postBytes, &application)
+	if unmarshalError != nil {
+		return nil, false, unexpectedResponse(postBytes)
 	}
-	return &application
+	return application, true, nil
 }
 
-func (client *IqClient) GetApplicationScm(applicationId string) *ApplicationScm {
-	getBytes := client.getHttpClient().HttpGet(client.IqServerUrl + applicationScmEndpoint + applicationId)
+func (client *IqClient) GetApplicationScm(applicationId string) (*ApplicationScm, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.IqServerUrl + applicationScmEndpoint + applicationId)
 	var applicationScm = new(ApplicationScm)
-	getError := json.Unmarshal(getBytes, &applicationScm)
+	if _, isHttpError := getError.(*auditHttp.HttpError); isHttpError {
+		// IQ Server returns error if SCM is not configured
+		return applicationScm, nil
+	}
 	if getError != nil {
+		return nil, getError
+	}
+	_ = json.Unmarshal(getBytes, &applicationScm)
+	return applicationScm, nil
+}
+
+func (client *IqClient) GetOrganizationScm(organizationId string) (*OrganizationScm, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.IqServerUrl + organizationScmEndpoint + organizationId)
+	var organizationScm = new(OrganizationScm)
+	if _, isHttpError := getError.(*auditHttp.HttpError); isHttpError {
 		// IQ Server returns error if SCM is not configured
-		return applicationScm
+		return organizationScm, nil
+	}
+	if getError != nil {
+		return nil, getError
 	}
-	return applicationScm
+	_ = json.Unmarshal(getBytes, &organizationScm)
+	return organizationScm, nil
 }
 
-func(client *IqClient) SetOrganizationScm(organizationId string, token string) {
-	client.getHttpClient().HttpPost(client.IqServerUrl + organizationScmEndpoint + organizationId, map[string]string {
+func(client *IqClient) SetOrganizationScm(organizationId string, provider string, token string, serverUrl string) error {
+	scm := map[string]string {
 		"token": token,
-		"provider": "GitHub",
-	})
+		"provider": provider,
+	}
+	if len(serverUrl) > 0 {
+		scm["baseUrl"] = serverUrl
+	}
+	_, postError := client.getHttpClient().HttpPost(client.IqServerUrl + organizationScmEndpoint + organizationId, scm)
+	return postError
 }
 
-func (client *IqClient) SetApplicationScm(applicationId string, repositoryUrl string) {
-	client.getHttpClient().HttpPost(client.IqServerUrl + applicationScmEndpoint + applicationId, map[string]string {
+func (client *IqClient) SetApplicationScm(applicationId string, provider string, repositoryUrl string) error {
+	_, postError := client.getHttpClient().HttpPost(client.IqServerUrl + applicationScmEndpoint + applicationId, map[string]string {
 		"repositoryUrl": repositoryUrl,
+		"provider": provider,
 	})
+	return postError
 }
 
-func (client *IqClient) ScanSbom(applicationId string, sbom sbom.Sbom) *SbomScanTicket {
-	postBytes := client.getHttpClient().HttpPostXml(client.IqServerUrl + scanEndpoint + applicationId + "/sources/cyclone", sbom)
-	sbomTicket := new(SbomScanTicket)
-	postError := json.Unmarshal(postBytes, &sbomTicket)
+// The stage is optional, IQ Server scans at its default stage for SBOMs when it is empty.
+func (client *IqClient) ScanSbom(applicationId string, stage string, sbom *sbom.Sbom) (*SbomScanTicket, error) {
+	return client.scanSbom(applicationId, stage, sbom)
+}
+
+// EvaluateFiles evaluates the files under path without the Nexus IQ CLI, their SHA-1 fingerprints are submitted as an
+// SBOM through the scan API at the given stage.
+func (client *IqClient) EvaluateFiles(path string, applicationId string, stage string) (*ApplicationEvaluationResult, error) {
+	fileSbom, sbomError := sbom.NewFileSbom(path)
+	if sbomError != nil {
+		return nil, sbomError
+	}
+	sbomScanTicket, scanError := client.scanSbom(applicationId, stage, fileSbom)
+	if scanError != nil {
+		return nil, scanError
+	}
+	sbomScanResult, resultError := client.GetSbomScanResult(sbomScanTicket.StatusUrl)
+	if resultError != nil {
+		return nil, resultError
+	}
+	applicationEvaluationResult := new(ApplicationEvaluationResult)
+	applicationEvaluationResult.ReportHtmlUrl = sbomScanResult.ReportHtmlUrl
+	return applicationEvaluationResult, nil
+}
+
+func (client *IqClient) scanSbom(applicationId string, stage string, bom *sbom.Sbom) (*SbomScanTicket, error) {
+	specVersion, versionError := client.GetSbomSpecVersion()
+	if versionError != nil {
+		return nil, versionError
+	}
+	format := client.SbomFormat
+	if len(format) == 0 {
+		format = sbom.FormatXml
+	}
+	sbomBytes, encodeError := bom.Encode(specVersion, format)
+	if encodeError != nil {
+		return nil, encodeError
+	}
+	scanUrl := client.IqServerUrl + scanEndpoint + applicationId + "/sources/cyclone"
+	if len(stage) > 0 {
+		scanUrl += "?stageId=" + url.QueryEscape(stage)
+	}
+	postBytes, postError := client.getHttpClient().HttpPostContent(scanUrl, sbom.ContentType(format), sbomBytes)
 	if postError != nil {
-		log.Fatal(string(postBytes))
+		return nil, postError
+	}
+	sbomTicket := new(SbomScanTicket)
+	unmarshalError := json.Unmarshal(postBytes, &sbomTicket)
+	if unmarshalError != nil || len(sbomTicket.StatusUrl) == 0 {
+		return nil, unexpectedResponse(postBytes)
 	}
-	return sbomTicket
+	return sbomTicket, nil
 }
 
-func (client *IqClient) GetSbomScanResult(statusUrl string) *SbomScanResult {
+func (client *IqClient) GetSbomScanResult(statusUrl string) (*SbomScanResult, error) {
 	sbomScanResult := new(SbomScanResult)
 	var errorQueue []string
 	for {
-		getBytes := client.getHttpClient().HttpGet(client.IqServerUrl + "/" + statusUrl)
-		getError := json.Unmarshal(getBytes, &sbomScanResult)
+		getBytes, getError := client.getHttpClient().HttpGet(client.IqServerUrl + "/" + statusUrl)
+		if getError == nil {
+			getError = json.Unmarshal(getBytes, &sbomScanResult)
+		}
 		if getError == nil {
 			break
 		} else {
 			errorQueue = append(errorQueue, string(getBytes))
 			if len(errorQueue) > 30 {
-				for _, errorMessage := range errorQueue {
-					log.Println(errorMessage)
-				}
-				os.Exit(1)
+				return nil, errors.New("SBOM scan result not available after 30 attempts - " + strings.Join(errorQueue, ", "))
 			}
 		}
 		time.Sleep(1 * time.Second)
 	}
-	return sbomScanResult
+	if sbomScanResult.IsError {
+		return nil, errors.New("SBOM scan failed - " + statusUrl)
+	}
+	return sbomScanResult, nil
 }
 
-func (client *IqClient) Evaluate(path string, applicationId string, stage string) *ApplicationEvaluationResult {
-	jarLocation, _ := filepath.Abs("./iq/nexus-iq-cli-1.78.0-02.jar")
+// CanEvaluateWithCli reports whether java and the Nexus IQ CLI jar are available to Evaluate with.
+func (client *IqClient) CanEvaluateWithCli() bool {
+	_, javaError := exec.LookPath(client.JavaExecutable)
+	_, jarError := os.Stat(client.CliJar)
+	return javaError == nil && jarError == nil
+}
+
+func (client *IqClient) Evaluate(path string, applicationId string, stage string) (*ApplicationEvaluationResult, error) {
+	jarLocation, _ := filepath.Abs(client.CliJar)
 	resultsFilePath := filepath.Join(path, "evaluation-results.json")
-	evaluateCommand := exec.Command("java", "-jar", jarLocation, "-s", client.IqServerUrl, "-a", client.Username + ":" + client.Password, "-i", applicationId, "-t", stage, "-r", resultsFilePath, path)
+	client.EvaluationLimiter.Acquire()
+	defer client.EvaluationLimiter.Release()
+	arguments := append(append([]string{}, client.JvmOptions...), "-jar", jarLocation, "-s", client.IqServerUrl, "-a", client.Username + ":" + client.Password, "-i", applicationId, "-t", stage, "-r", resultsFilePath, path)
+	// The credentials are passed in an argument file readable only by this user, so they never show up in the process list,
+	// Java 8 has no argument files and only gets them on the command line when allowed
+	evaluateCommand := exec.Command(client.JavaExecutable, arguments...)
+	if client.supportsArgumentFiles() {
+		argumentFile, argumentError := writeArgumentFile(arguments)
+		if argumentError != nil {
+			return nil, argumentError
+		}
+		defer os.Remove(argumentFile)
+		evaluateCommand = exec.Command(client.JavaExecutable, "@" + argumentFile)
+	} else if !client.AllowCommandLineCredentials {
+		return nil, errors.New(fmt.Sprintf("Java %v does not support argument files and would show the Nexus IQ CLI credentials in the process list, use Java 9 or later or allowCliCredentialsOnCommandLine - %v", client.javaVersion, client.JavaExecutable))
+	}
 	var stdout, stderr bytes.Buffer
 	evaluateCommand.Stdout = &stdout
 	evaluateCommand.Stderr = &stderr
 	exitError := evaluateCommand.Run()
 	if exitError != nil {
-		outStr, errStr := string(stdout.Bytes()), string(stderr.Bytes())
+		outStr, errStr := client.redact(string(stdout.Bytes())), client.redact(string(stderr.Bytes()))
 		log.Println(outStr)
-		log.Fatal(errStr)
+		return nil, errors.New("Nexus IQ CLI evaluation failed - " + client.redact(exitError.Error()) + " - " + errStr)
 	}
-	jsonFile, openError := os.Open(resultsFilePath)
-	if openError != nil {
-		log.Fatal(openError.Error())
-	}
-	defer jsonFile.Close()
-
-	resultBytes, readError := ioutil.ReadAll(jsonFile)
+	resultBytes, readError := ioutil.ReadFile(resultsFilePath)
 	if readError != nil {
-		log.Fatal(readError.Error())
+		return nil, readError
 	}
 
 	applicationEvaluationResult := new(ApplicationEvaluationResult)
 	unmarshalError := json.Unmarshal(resultBytes, applicationEvaluationResult)
 	if unmarshalError != nil {
-		log.Fatal(unmarshalError.Error())
+		return nil, unmarshalError
 	}
 
-	return applicationEvaluationResult
+	return applicationEvaluationResult, nil
 }
 
+func unexpectedResponse(responseBytes []byte) error {
+	return errors.New("unexpected response from IQ Server - " + string(responseBytes))
+}
 
 func (client *IqClient) getHttpClient() *auditHttp.HttpClient {
 	httpClient := new(auditHttp.HttpClient)
 	httpClient.Username = client.Username
 	httpClient.Password = client.Password
+	httpClient.Limiter = client.Limiter
 	return httpClient
 }
\ No newline at end of file
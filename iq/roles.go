@@ -0,0 +1,69 @@
+package iq
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+const rolesEndpoint = apiEndpoint + "roles"
+const usersEndpoint = apiEndpoint + "users"
+const applicationRoleMembershipEndpoint = apiEndpoint + "roleMemberships/application/"
+
+type Roles struct {
+	Roles []Role
+}
+
+type Role struct {
+	Id string
+	Name string
+}
+
+type Users struct {
+	Users []User
+}
+
+type User struct {
+	Username string
+	Email string
+}
+
+// GetRole finds a role by name, ignoring case.
+func (client *IqClient) GetRole(roleName string) (*Role, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.IqServerUrl + rolesEndpoint)
+	if getError != nil {
+		return nil, getError
+	}
+	roles := new(Roles)
+	unmarshalError := json.Unmarshal(getBytes, &roles)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
+	}
+	for _, role := range roles.Roles {
+		if strings.EqualFold(role.Name, roleName) {
+			return &role, nil
+		}
+	}
+	return nil, errors.New("IQ role not found - " + roleName)
+}
+
+func (client *IqClient) GetUsers() ([]User, error) {
+	getBytes, getError := client.getHttpClient().HttpGet(client.IqServerUrl + usersEndpoint)
+	if getError != nil {
+		return nil, getError
+	}
+	users := new(Users)
+	unmarshalError := json.Unmarshal(getBytes, &users)
+	if unmarshalError != nil {
+		return nil, unexpectedResponse(getBytes)
+	}
+	return users.Users, nil
+}
+
+// AddApplicationRoleMember grants a user a role on an application, granting a role the user already has is not an error.
+func (client *IqClient) AddApplicationRoleMember(applicationId string, roleId string, username string) error {
+	_, putError := client.getHttpClient().HttpPut(client.IqServerUrl + applicationRoleMembershipEndpoint + applicationId +
+		"/role/" + roleId + "/user/" + url.PathEscape(username), nil)
+	return putError
+}
@@ -2,19 +2,33 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
+	textTemplate "text/template"
 	"io"
+	"iq-scm-audit/azure"
+	"iq-scm-audit/bitbucket"
 	"iq-scm-audit/github"
+	"iq-scm-audit/gitlab"
+	auditHttp "iq-scm-audit/http"
 	"iq-scm-audit/iq"
 	"iq-scm-audit/sbom"
+	"iq-scm-audit/scm"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+const (
+	EvaluatorAuto = "auto"
+	EvaluatorCli = "cli"
+	EvaluatorNative = "native"
+)
+
 type IssueData struct {
 	IqServerUrl string
 	AuditReportUrl string
@@ -23,19 +37,74 @@ type IssueData struct {
 	Repository string
 	Contact string
 	NameWithOwner string
+	BuildSystem *BuildSystem
+	Workflow string
 }
 
 type AuditConfiguration struct {
+	ScmProvider              string
+	GitHubUrl                string
 	GitHubToken              *string
 	GitHubQuery              *string
+	GitHubAppId              string
+	GitHubAppPrivateKey      string
+	GitHubAppInstallationId  string
+	GitLabUrl                string
+	GitLabToken              *string
+	GitLabQuery              *string
+	BitbucketUrl             *string
+	BitbucketToken           *string
+	BitbucketQuery           *string
+	JiraUrl                  string
+	JiraToken                string
+	JiraProject              string
+	AzureUrl                 *string
+	AzureToken               *string
+	AzureQuery               *string
+	AzureWorkItemType        string
+	AzureFeed                string
 	IqServerUrl              *string
 	IqUsername               *string
 	IqPassword               *string
 	IqOrganization           *string
+	IqOrganizationMapping    string
+	IqRole                   string
+	IqCategoryRules          string
+	VersionRegistry          string
+	CycloneDxVersion         string
+	CycloneDxFormat          string
+	SbomOutputDir            string
+	SbomOutputFormats        string
+	SbomOnly                 bool
 	IqContact                *string
 	SkipIssueCreation        bool
 	SkipExistingApplications bool
 	SkipIQEvaluations		 bool
+	DryRun                   bool
+	CreatePullRequest        bool
+	ReportFile               string
+	ReportFormat             string
+	Concurrency              int
+	IqConcurrency            int
+	ScmConcurrency           int
+	EvaluationConcurrency    int
+	StateFile                string
+	Resume                   bool
+	Incremental              bool
+	Evaluator                string
+	ConfigFile               string
+	IssueTemplate            string
+	SbomStage                string
+	ReleaseStage             string
+	PackageStage             string
+	IqCliJar                 string
+	JavaExecutable           string
+	JvmOptions               string
+	IqCliDownload            bool
+	IqCliDownloadUrl         string
+	IqCliCacheDirectory      string
+	IqCliSha256              string
+	AllowCliCredentials      bool
 }
 
 type RequiredFlag struct {
@@ -43,12 +112,21 @@ type RequiredFlag struct {
 	Name string
 	Usage string
 	EnvironmentalVariable string
+	Provider string
 }
 
 func main() {
 	configuration := new(AuditConfiguration)
 	configuration.GitHubToken = new(string)
 	configuration.GitHubQuery = new(string)
+	configuration.GitLabToken = new(string)
+	configuration.GitLabQuery = new(string)
+	configuration.BitbucketUrl = new(string)
+	configuration.BitbucketToken = new(string)
+	configuration.BitbucketQuery = new(string)
+	configuration.AzureUrl = new(string)
+	configuration.AzureToken = new(string)
+	configuration.AzureQuery = new(string)
 	configuration.IqServerUrl = new(string)
 	configuration.IqUsername = new(string)
 	configuration.IqPassword = new(string)
@@ -56,17 +134,71 @@ func main() {
 	configuration.IqContact = new(string)
 
 	var requiredFlags []RequiredFlag
-	requiredFlags = appendFlag(requiredFlags, configuration.GitHubToken, "gitHubToken", "GitHub Token", "GITHUB_TOKEN")
-	requiredFlags = appendFlag(requiredFlags, configuration.GitHubQuery, "gitHubQuery", "Query String for GitHub graphql repository search", "GITHUB_QUERY")
+	requiredFlags = appendProviderFlag(requiredFlags, configuration.GitHubToken, "gitHubToken", "GitHub Token, when authenticating as a GitHub App it is optional and only configured on the IQ Organization source control", "GITHUB_TOKEN", github.Provider)
+	requiredFlags = appendProviderFlag(requiredFlags, configuration.GitHubQuery, "gitHubQuery", "Query String for GitHub graphql repository search", "GITHUB_QUERY", github.Provider)
+	requiredFlags = appendProviderFlag(requiredFlags, configuration.GitLabToken, "gitLabToken", "GitLab Token", "GITLAB_TOKEN", gitlab.Provider)
+	requiredFlags = appendProviderFlag(requiredFlags, configuration.GitLabQuery, "gitLabQuery", "Query String for GitLab project search (e.g. group:my-group)", "GITLAB_QUERY", gitlab.Provider)
+	requiredFlags = appendProviderFlag(requiredFlags, configuration.BitbucketUrl, "bitbucketUrl", "Bitbucket Server Url", "BITBUCKET_URL", bitbucket.Provider)
+	requiredFlags = appendProviderFlag(requiredFlags, configuration.BitbucketToken, "bitbucketToken", "Bitbucket Server HTTP Access Token", "BITBUCKET_TOKEN", bitbucket.Provider)
+	requiredFlags = appendProviderFlag(requiredFlags, configuration.BitbucketQuery, "bitbucketQuery", "Query String for Bitbucket Server repository search (e.g. project:KEY name)", "BITBUCKET_QUERY", bitbucket.Provider)
+	requiredFlags = appendProviderFlag(requiredFlags, configuration.AzureUrl, "azureUrl", "Azure DevOps Organization Url (e.g. https://dev.azure.com/my-organization)", "AZURE_URL", azure.Provider)
+	requiredFlags = appendProviderFlag(requiredFlags, configuration.AzureToken, "azureToken", "Azure DevOps Personal Access Token", "AZURE_TOKEN", azure.Provider)
+	requiredFlags = appendProviderFlag(requiredFlags, configuration.AzureQuery, "azureQuery", "Query String for Azure DevOps repository search (e.g. project:Name name)", "AZURE_QUERY", azure.Provider)
 	requiredFlags = appendFlag(requiredFlags, configuration.IqServerUrl, "iqServerUrl", "Nexus IQ Server Url", "IQ_SERVER_URL")
 	requiredFlags = appendFlag(requiredFlags, configuration.IqUsername, "iqUsername", "Nexus IQ Username", "IQ_USERNAME")
 	requiredFlags = appendFlag(requiredFlags, configuration.IqPassword, "iqPassword", "Nexus IQ Password", "IQ_PASSWORD")
-	requiredFlags = appendFlag(requiredFlags, configuration.IqOrganization, "iqOrganization", "Organization to create new applications", "IQ_ORGANIZATION")
+	requiredFlags = appendFlag(requiredFlags, configuration.IqOrganization, "iqOrganization", "Organization to create new applications, the root of the mapped IQ Organizations with iqOrganizationMapping", "IQ_ORGANIZATION")
 	requiredFlags = appendFlag(requiredFlags, configuration.IqContact, "iqcontact", "Email of person to contact for access to Nexus IQ", "IQ_CONTACT")
 
-	flag.BoolVar(&configuration.SkipIssueCreation,"skipIssueCreation", false, "Skip GitHub Issue Creation")
+	flag.StringVar(&configuration.IqOrganizationMapping, "iqOrganizationMapping", getEnvOrDefault("IQ_ORGANIZATION_MAPPING", OrganizationMappingNone), "Create new applications in child IQ Organizations of iqOrganization, none, owner for one per source control organization or owner, or team for one per owning GitHub team within those (IQ_ORGANIZATION_MAPPING)")
+	flag.StringVar(&configuration.IqRole, "iqRole", os.Getenv("IQ_ROLE"), "IQ role, e.g. Developer, to grant the GitHub admins and maintainers of each repository on its new IQ Application, matched to IQ users by email or username (IQ_ROLE)")
+	flag.StringVar(&configuration.IqCategoryRules, "iqCategoryRules", os.Getenv("IQ_CATEGORY_RULES"), "Comma separated attribute:value=Category rules applying IQ Application Categories from the repository topic, language, visibility or archived status, e.g. visibility:public=Distributed,topic:service=Hosted (IQ_CATEGORY_RULES)")
+	flag.StringVar(&configuration.VersionRegistry, "versionRegistry", os.Getenv("VERSION_REGISTRY"), "JSON file of the published versions of packages, dependencies with only a version range are scanned at the highest version it allows instead of being left out (VERSION_REGISTRY)")
+	flag.StringVar(&configuration.CycloneDxVersion, "cycloneDxVersion", os.Getenv("CYCLONEDX_VERSION"), "CycloneDX spec version of the SBOMs scanned, 1.1 to 1.5, defaults to the newest the IQ Server accepts (CYCLONEDX_VERSION)")
+	flag.StringVar(&configuration.CycloneDxFormat, "cycloneDxFormat", getEnvOrDefault("CYCLONEDX_FORMAT", sbom.FormatXml), "Encoding of the SBOMs scanned, xml or json, json needs CycloneDX 1.2 or later (CYCLONEDX_FORMAT)")
+	flag.StringVar(&configuration.SbomOutputDir, "sbomOutputDir", os.Getenv("SBOM_OUTPUT_DIR"), "Directory to write the SBOM of each repository's dependencies to, as <owner>/<repository>.cdx.xml and the other sbomOutputFormats (SBOM_OUTPUT_DIR)")
+	flag.StringVar(&configuration.SbomOutputFormats, "sbomOutputFormats", getEnvOrDefault("SBOM_OUTPUT_FORMATS", SbomFileCycloneDxXml), "Comma separated formats of the SBOMs written to sbomOutputDir, cdx-xml, cdx-json, spdx or spdx-json (SBOM_OUTPUT_FORMATS)")
+	flag.StringVar(&configuration.ScmProvider, "scmProvider", getEnvOrDefault("SCM_PROVIDER", github.Provider), "Source control provider, one of github, gitlab, bitbucket or azure (SCM_PROVIDER)")
+	flag.StringVar(&configuration.GitHubUrl, "gitHubUrl", getEnvOrDefault("GITHUB_URL", github.CloudUrl), "GitHub Url, set to the GitHub Enterprise Server Url to audit an Enterprise Server (GITHUB_URL)")
+	flag.StringVar(&configuration.GitHubAppId, "gitHubAppId", os.Getenv("GITHUB_APP_ID"), "GitHub App ID to authenticate as instead of a GitHub Token (GITHUB_APP_ID)")
+	flag.StringVar(&configuration.GitHubAppPrivateKey, "gitHubAppPrivateKey", os.Getenv("GITHUB_APP_PRIVATE_KEY"), "Path to the GitHub App private key PEM file (GITHUB_APP_PRIVATE_KEY)")
+	flag.StringVar(&configuration.GitHubAppInstallationId, "gitHubAppInstallationId", os.Getenv("GITHUB_APP_INSTALLATION_ID"), "GitHub App installation ID, every installation of the GitHub App is audited when not supplied (GITHUB_APP_INSTALLATION_ID)")
+	flag.StringVar(&configuration.GitLabUrl, "gitLabUrl", getEnvOrDefault("GITLAB_URL", gitlab.CloudUrl), "GitLab Url (GITLAB_URL)")
+	flag.StringVar(&configuration.JiraUrl, "jiraUrl", os.Getenv("JIRA_URL"), "Jira Url to file Bitbucket Server issues in, otherwise the newest open pull request is commented on (JIRA_URL)")
+	flag.StringVar(&configuration.JiraToken, "jiraToken", os.Getenv("JIRA_TOKEN"), "Jira Personal Access Token (JIRA_TOKEN)")
+	flag.StringVar(&configuration.JiraProject, "jiraProject", os.Getenv("JIRA_PROJECT"), "Jira Project Key to file Bitbucket Server issues in (JIRA_PROJECT)")
+	flag.StringVar(&configuration.AzureWorkItemType, "azureWorkItemType", getEnvOrDefault("AZURE_WORK_ITEM_TYPE", "Task"), "Azure DevOps Work Item type created instead of issues (AZURE_WORK_ITEM_TYPE)")
+	flag.StringVar(&configuration.AzureFeed, "azureFeed", os.Getenv("AZURE_FEED"), "Azure Artifacts feed to evaluate packages named after each repository from (AZURE_FEED)")
+
+	flag.BoolVar(&configuration.SkipIssueCreation,"skipIssueCreation", false, "Skip Issue Creation in source control")
 	flag.BoolVar(&configuration.SkipExistingApplications, "skipExistingApplications", false, "Skip Audit and Evaluation against existing applications")
 	flag.BoolVar(&configuration.SkipIQEvaluations, "skipIQEvaluations", false, "Skip IQ Evaluations against latest Release or Package assets")
+	flag.StringVar(&configuration.ConfigFile, "config", os.Getenv("CONFIG_FILE"), "YAML or JSON file of settings and audit targets, command line options override its values (CONFIG_FILE)")
+	flag.StringVar(&configuration.IssueTemplate, "issueTemplate", getEnvOrDefault("ISSUE_TEMPLATE", "github-issue.md"), "Markdown template of the issues opened (ISSUE_TEMPLATE)")
+	flag.StringVar(&configuration.SbomStage, "sbomStage", os.Getenv("IQ_SBOM_STAGE"), "IQ stage to scan SBOMs at, defaults to the IQ Server default for SBOM scans (IQ_SBOM_STAGE)")
+	flag.StringVar(&configuration.ReleaseStage, "releaseStage", getEnvOrDefault("IQ_RELEASE_STAGE", "stage-release"), "IQ stage to evaluate the latest release at (IQ_RELEASE_STAGE)")
+	flag.StringVar(&configuration.PackageStage, "packageStage", getEnvOrDefault("IQ_PACKAGE_STAGE", "release"), "IQ stage to evaluate the latest package at (IQ_PACKAGE_STAGE)")
+	flag.StringVar(&configuration.Evaluator, "evaluator", getEnvOrDefault("IQ_EVALUATOR", EvaluatorAuto), "How releases and packages are evaluated, cli runs the Nexus IQ CLI, native submits SHA-1 fingerprints through the IQ scan API and auto uses the CLI when java and the jar are available (IQ_EVALUATOR)")
+	flag.StringVar(&configuration.IqCliJar, "iqCliJar", getEnvOrDefault("IQ_CLI_JAR", iq.DefaultCliJar), "Path to the Nexus IQ CLI jar (IQ_CLI_JAR)")
+	flag.StringVar(&configuration.JavaExecutable, "javaExecutable", getEnvOrDefault("JAVA_EXECUTABLE", "java"), "Java executable to run the Nexus IQ CLI with (JAVA_EXECUTABLE)")
+	flag.StringVar(&configuration.JvmOptions, "jvmOptions", os.Getenv("JVM_OPTIONS"), "Space separated JVM options for the Nexus IQ CLI, e.g. -Xmx2g -Dhttps.proxyHost=proxy (JVM_OPTIONS)")
+	flag.BoolVar(&configuration.IqCliDownload, "iqCliDownload", false, "Download the Nexus IQ CLI matching the IQ Server version into iqCliCacheDirectory instead of using iqCliJar")
+	flag.StringVar(&configuration.IqCliDownloadUrl, "iqCliDownloadUrl", getEnvOrDefault("IQ_CLI_DOWNLOAD_URL", iq.DefaultCliDownloadUrl), "Url to download the Nexus IQ CLI from, {version} is replaced by the IQ Server version (IQ_CLI_DOWNLOAD_URL)")
+	flag.StringVar(&configuration.IqCliCacheDirectory, "iqCliCacheDirectory", os.Getenv("IQ_CLI_CACHE_DIRECTORY"), "Directory to cache downloaded Nexus IQ CLI jars in, defaults to iq-scm-audit in the user cache directory (IQ_CLI_CACHE_DIRECTORY)")
+	flag.StringVar(&configuration.IqCliSha256, "iqCliSha256", os.Getenv("IQ_CLI_SHA256"), "Expected SHA-256 of the downloaded Nexus IQ CLI jar, required when iqCliDownloadUrl is overridden, defaults to the .sha256 file next to the Sonatype download (IQ_CLI_SHA256)")
+	flag.BoolVar(&configuration.AllowCliCredentials, "allowCliCredentialsOnCommandLine", false, "Pass the IQ credentials to the Nexus IQ CLI on the command line when java is older than Java 9 and has no argument files, other users can see them in the process list")
+	flag.BoolVar(&configuration.CreatePullRequest, "createPullRequest", false, "Open a GitHub pull request adding a Nexus IQ GitHub Actions workflow instead of an issue when the build system is detected")
+	flag.StringVar(&configuration.ReportFile, "reportFile", os.Getenv("REPORT_FILE"), "Path to write a report of every audited repository to (REPORT_FILE)")
+	flag.StringVar(&configuration.ReportFormat, "reportFormat", os.Getenv("REPORT_FORMAT"), "Report format, json or csv, defaults to the reportFile extension (REPORT_FORMAT)")
+	flag.BoolVar(&configuration.SbomOnly, "sbomOnly", false, "Only get the source control repositories and write their SBOMs to sbomOutputDir, without IQ Server or issues")
+	flag.BoolVar(&configuration.DryRun, "dryRun", false, "Report the IQ and source control changes that would be made without making them")
+	flag.StringVar(&configuration.StateFile, "stateFile", getEnvOrDefault("STATE_FILE", "iq-scm-audit-state.json"), "Path to record the progress of each repository to (STATE_FILE)")
+	flag.BoolVar(&configuration.Resume, "resume", false, "Resume an interrupted audit from stateFile, skipping the repository search and the stages already completed")
+	flag.BoolVar(&configuration.Incremental, "incremental", false, "Only scan SBOMs and evaluate releases or packages that changed since they were last audited according to stateFile")
+	flag.IntVar(&configuration.Concurrency, "concurrency", 1, "Number of repositories audited at the same time")
+	flag.IntVar(&configuration.IqConcurrency, "iqConcurrency", 0, "Maximum simultaneous IQ API requests, defaults to concurrency")
+	flag.IntVar(&configuration.ScmConcurrency, "scmConcurrency", 0, "Maximum simultaneous source control REST requests, including downloads, defaults to concurrency")
+	flag.IntVar(&configuration.EvaluationConcurrency, "evaluationConcurrency", 0, "Maximum simultaneous Nexus IQ CLI evaluations, defaults to concurrency")
 
 	flag.Usage = func() {
 		_, _ = fmt.Fprint(os.Stdout, "Usage: \niq-scm-audit [options]\n")
@@ -78,63 +210,339 @@ func main() {
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	if len(configuration.ConfigFile) == 0 {
+		exitOnInvalidConfiguration(configuration, requiredFlags, "")
+		auditError := audit(configuration)
+		if auditError != nil {
+			log.Fatal(auditError)
+		}
+		return
+	}
+
+	settings, targets, configError := loadConfigFile(configuration.ConfigFile)
+	if configError != nil {
+		log.Fatal(configError)
+	}
+	explicitFlags := make(map[string]bool)
+	flag.Visit(func(explicitFlag *flag.Flag) {
+		explicitFlags[explicitFlag.Name] = true
+	})
+	// Every target is checked before any is audited, so a mistake in the last target does not stop a long run halfway
+	for _, target := range targets {
+		applyError := applyTarget(settings, target, explicitFlags, len(targets) > 1)
+		if applyError != nil {
+			log.Fatal(applyError)
+		}
+		exitOnInvalidConfiguration(configuration, requiredFlags, target.Name)
+	}
+	var failedTargets []string
+	for _, target := range targets {
+		_ = applyTarget(settings, target, explicitFlags, len(targets) > 1)
+		exitOnInvalidConfiguration(configuration, requiredFlags, target.Name)
+		log.Println("Auditing target - " + target.Name)
+		auditError := audit(configuration)
+		if auditError != nil {
+			log.Println("Target failed - " + target.Name + " - " + auditError.Error())
+			failedTargets = append(failedTargets, target.Name)
+		}
+	}
+	if len(failedTargets) > 0 {
+		log.Fatal(fmt.Sprintf("%v of %v targets failed - %v", len(failedTargets), len(targets), strings.Join(failedTargets, ", ")))
+	}
+}
+
+func exitOnInvalidConfiguration(configuration *AuditConfiguration, requiredFlags []RequiredFlag, target string) {
+	var prefix string
+	if len(target) > 0 {
+		prefix = "Target " + target + " - "
+	}
+	if !isSupportedProvider(configuration.ScmProvider) {
+		_, _ = fmt.Fprint(os.Stdout, "\n"+prefix+"Unsupported source control provider: "+configuration.ScmProvider+".\n")
+		flag.Usage()
+
+		os.Exit(1)
+	}
 
 	for _, requiredFlag := range requiredFlags {
+		if len(requiredFlag.Provider) > 0 && requiredFlag.Provider != configuration.ScmProvider {
+			continue
+		}
 		if len(*requiredFlag.Field) == 0 {
 			*requiredFlag.Field = os.Getenv(requiredFlag.EnvironmentalVariable)
 		}
+		if requiredFlag.Field == configuration.GitHubToken && len(configuration.GitHubAppId) > 0 {
+			continue
+		}
+		// Only the source control flags are needed to write SBOMs
+		if len(requiredFlag.Provider) == 0 && configuration.SbomOnly {
+			continue
+		}
 		if len(*requiredFlag.Field) == 0 {
-			_, _ = fmt.Fprint(os.Stdout, "\nMissing required argument: "+requiredFlag.Usage+". Supply via command line ("+requiredFlag.Name+") or environmental variable ("+requiredFlag.EnvironmentalVariable+").\n")
+			_, _ = fmt.Fprint(os.Stdout, "\n"+prefix+"Missing required argument: "+requiredFlag.Usage+". Supply via command line ("+requiredFlag.Name+"), configuration file or environmental variable ("+requiredFlag.EnvironmentalVariable+").\n")
 			flag.Usage()
 
 			os.Exit(1)
 		}
 	}
-
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	audit(configuration)
 }
 
 func appendFlag(flags []RequiredFlag, field *string, name string, usage string, environmentalVariable string) []RequiredFlag {
+	return appendProviderFlag(flags, field, name, usage, environmentalVariable, "")
+}
+
+func appendProviderFlag(flags []RequiredFlag, field *string, name string, usage string, environmentalVariable string, provider string) []RequiredFlag {
 	flag.StringVar(field, name, "", usage + " (" + environmentalVariable + ")")
 	requiredFlag := new(RequiredFlag)
 	requiredFlag.Field = field
 	requiredFlag.Name = name
 	requiredFlag.Usage = usage
 	requiredFlag.EnvironmentalVariable = environmentalVariable
+	requiredFlag.Provider = provider
 	return append(flags, *requiredFlag)
 }
 
-func audit(configuration *AuditConfiguration) {
+func getEnvOrDefault(environmentalVariable string, defaultValue string) string {
+	value := os.Getenv(environmentalVariable)
+	if len(value) == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func isSupportedProvider(provider string) bool {
+	switch provider {
+	case github.Provider, gitlab.Provider, bitbucket.Provider, azure.Provider:
+		return true
+	}
+	return false
+}
+
+// newLimiter bounds requests by limit, a limit left at 0 defaults to the number of repositories audited at once.
+func newLimiter(limit int, concurrency int) auditHttp.Limiter {
+	if limit == 0 {
+		return auditHttp.NewLimiter(concurrency)
+	}
+	return auditHttp.NewLimiter(limit)
+}
+
+// Every REST request of the returned client, including downloads, is bounded by scmConcurrency.
+func newScmClient(configuration *AuditConfiguration) (scm.Client, string, string, error) {
+	scmLimiter := newLimiter(configuration.ScmConcurrency, configuration.Concurrency)
+	switch configuration.ScmProvider {
+	case azure.Provider:
+		azureClient := azure.NewAzureClient(*configuration.AzureUrl, *configuration.AzureToken)
+		azureClient.Limiter = scmLimiter
+		azureClient.WorkItemType = configuration.AzureWorkItemType
+		azureClient.Feed = configuration.AzureFeed
+		return azureClient, *configuration.AzureToken, *configuration.AzureQuery, nil
+	case bitbucket.Provider:
+		bitbucketClient := bitbucket.NewBitbucketClient(*configuration.BitbucketUrl, *configuration.BitbucketToken)
+		bitbucketClient.JiraUrl = configuration.JiraUrl
+		bitbucketClient.JiraToken = configuration.JiraToken
+		bitbucketClient.JiraProject = configuration.JiraProject
+		bitbucketClient.Limiter = scmLimiter
+		return bitbucketClient, *configuration.BitbucketToken, *configuration.BitbucketQuery, nil
+	case gitlab.Provider:
+		gitLabClient := gitlab.NewGitLabClient(configuration.GitLabUrl, *configuration.GitLabToken)
+		gitLabClient.Limiter = scmLimiter
+		return gitLabClient, *configuration.GitLabToken, *configuration.GitLabQuery, nil
+	default:
+		if len(configuration.GitHubAppId) > 0 {
+			return newGitHubAppClient(configuration, scmLimiter)
+		}
+		gitHubClient := github.NewGitHubClient(configuration.GitHubUrl, *configuration.GitHubToken)
+		gitHubClient.Limiter = scmLimiter
+		return gitHubClient, *configuration.GitHubToken, *configuration.GitHubQuery, nil
+	}
+}
+
+func newGitHubAppClient(configuration *AuditConfiguration, scmLimiter auditHttp.Limiter) (scm.Client, string, string, error) {
+	if len(configuration.GitHubAppPrivateKey) == 0 {
+		return nil, "", "", errors.New("missing required argument: GitHub App private key. Supply via command line (gitHubAppPrivateKey) or environmental variable (GITHUB_APP_PRIVATE_KEY)")
+	}
+	var installationId int64
+	if len(configuration.GitHubAppInstallationId) > 0 {
+		var parseError error
+		installationId, parseError = strconv.ParseInt(configuration.GitHubAppInstallationId, 10, 64)
+		if parseError != nil {
+			return nil, "", "", parseError
+		}
+	}
+	gitHubApp, appError := github.NewGitHubApp(configuration.GitHubAppId, configuration.GitHubAppPrivateKey)
+	if appError != nil {
+		return nil, "", "", appError
+	}
+	gitHubClient := github.NewGitHubAppClient(configuration.GitHubUrl, gitHubApp, installationId)
+	gitHubClient.Limiter = scmLimiter
+	// Installation tokens expire after an hour, IQ keeps the source control token, so only a long lived one is given to it
+	if len(*configuration.GitHubToken) == 0 {
+		log.Println("GitHub App installation tokens expire, supply gitHubToken to configure IQ Organization source control")
+	}
+	return gitHubClient, *configuration.GitHubToken, *configuration.GitHubQuery, nil
+}
+
+// Failures of a single repository are recorded against it and the audit continues with the rest, only failures
+// that affect every repository are returned.
+func audit(configuration *AuditConfiguration) error {
+	if configuration.SbomOnly {
+		return writeRepositorySboms(configuration)
+	}
 	log.Println("Getting IQ Applications")
 	var iqClient = iq.NewIqClient(*configuration.IqServerUrl, *configuration.IqUsername, *configuration.IqPassword)
-	var applications = iqClient.GetApplications()
+	iqClient.Limiter = newLimiter(configuration.IqConcurrency, configuration.Concurrency)
+	iqClient.EvaluationLimiter = newLimiter(configuration.EvaluationConcurrency, configuration.Concurrency)
+	iqClient.CliJar = configuration.IqCliJar
+	iqClient.JavaExecutable = configuration.JavaExecutable
+	iqClient.JvmOptions = strings.Fields(configuration.JvmOptions)
+	iqClient.AllowCommandLineCredentials = configuration.AllowCliCredentials
+	iqClient.SbomSpecVersion = configuration.CycloneDxVersion
+	iqClient.SbomFormat = configuration.CycloneDxFormat
+	if configuration.IqCliDownload && !configuration.SkipIQEvaluations && configuration.Evaluator != EvaluatorNative {
+		if len(configuration.IqCliCacheDirectory) == 0 {
+			configuration.IqCliCacheDirectory = defaultCacheDirectory()
+		}
+		// A checksum fetched from the mirror serving the jar cannot tell a tampered jar apart, only the Sonatype one is trusted
+		if len(configuration.IqCliSha256) == 0 && configuration.IqCliDownloadUrl != iq.DefaultCliDownloadUrl {
+			return errors.New("iqCliDownloadUrl is overridden, supply the expected SHA-256 of the Nexus IQ CLI jar in iqCliSha256")
+		}
+		downloadError := iqClient.DownloadCli(configuration.IqCliDownloadUrl, configuration.IqCliCacheDirectory, configuration.IqCliSha256)
+		if downloadError != nil {
+			return downloadError
+		}
+	}
+	switch configuration.Evaluator {
+	case EvaluatorCli, EvaluatorNative:
+	case EvaluatorAuto:
+		configuration.Evaluator = EvaluatorNative
+		if iqClient.CanEvaluateWithCli() {
+			configuration.Evaluator = EvaluatorCli
+		}
+		log.Println("Evaluating releases and packages with the " + configuration.Evaluator + " evaluator")
+	default:
+		return errors.New("unsupported evaluator - " + configuration.Evaluator)
+	}
+	sbomError := validateSbomConfiguration(configuration)
+	if sbomError != nil {
+		return sbomError
+	}
+	applications, applicationsError := iqClient.GetApplications()
+	if applicationsError != nil {
+		return applicationsError
+	}
 	log.Println("Getting or Creating IQ Organization - " + *configuration.IqOrganization)
-	var scmOrganization = iqClient.GetOrCreateOrganization(*configuration.IqOrganization)
-	iqClient.SetOrganizationScm(scmOrganization.Id, *configuration.GitHubToken)
+	scmClient, scmToken, scmQuery, scmError := newScmClient(configuration)
+	if scmError != nil {
+		return scmError
+	}
+	var plan = new(Plan)
+	var scmOrganization *iq.Organization
+	var organizationError error
+	if configuration.DryRun {
+		scmOrganization, organizationError = planOrganization(iqClient, plan, *configuration.IqOrganization)
+	} else {
+		scmOrganization, organizationError = iqClient.GetOrCreateOrganization(*configuration.IqOrganization, "")
+	}
+	if organizationError != nil {
+		return organizationError
+	}
+	if len(scmToken) > 0 && configuration.DryRun {
+		organizationScmError := planOrganizationScm(iqClient, plan, scmOrganization, scmClient)
+		if organizationScmError != nil {
+			return organizationScmError
+		}
+	} else if len(scmToken) > 0 {
+		organizationScmError := iqClient.SetOrganizationScm(scmOrganization.Id, scmClient.Provider(), scmToken, scmClient.ServerUrl())
+		if organizationScmError != nil {
+			return organizationScmError
+		}
+	} else {
+		log.Println("No single source control token to configure on IQ Organization, Skipping - " + scmOrganization.Name)
+	}
+	organizations, mappingError := NewOrganizationTree(iqClient, scmClient, scmOrganization, configuration.IqOrganizationMapping, configuration.DryRun)
+	if mappingError != nil {
+		return mappingError
+	}
+	roleMembers, roleError := NewRoleMembers(iqClient, scmClient, configuration.IqRole)
+	if roleError != nil {
+		return roleError
+	}
+	categoryRules, rulesError := parseCategoryRules(configuration.IqCategoryRules)
+	if rulesError != nil {
+		return rulesError
+	}
+	resolver, resolverError := newResolver(configuration)
+	if resolverError != nil {
+		return resolverError
+	}
 
-	log.Println("Getting GitHub Repositories")
-	var gitHubClient = github.NewGitHubClient(*configuration.GitHubToken)
-	var repositories = gitHubClient.GetRepositories(*configuration.GitHubQuery)
+	state, stateError := LoadState(configuration.StateFile)
+	if stateError != nil {
+		return stateError
+	}
+	if !configuration.Resume {
+		state.Reset()
+	}
 
-	issueTemplate, templateError := template.ParseFiles("github-issue.md")
+	var repositories []scm.Repository
+	if configuration.Resume && state.HasRepositories(scmClient.Provider(), scmQuery) {
+		log.Println("Resuming with " + scmClient.Provider() + " Repositories from state file - " + configuration.StateFile)
+		repositories = state.Repositories
+	} else {
+		if configuration.Resume && len(state.FailedRepositories) > 0 {
+			log.Println(fmt.Sprintf("Searching again, %v Repositories could not be read by the interrupted audit - %v", len(state.FailedRepositories), strings.Join(state.FailedRepositories, ", ")))
+		}
+		log.Println("Getting " + scmClient.Provider() + " Repositories")
+		var repositoriesError error
+		repositories, repositoriesError = scmClient.GetRepositories(scmQuery)
+		if repositoriesError != nil {
+			return repositoriesError
+		}
+		if !configuration.DryRun {
+			stateError := state.SetRepositories(scmClient.Provider(), scmQuery, repositories)
+			if stateError != nil {
+				return stateError
+			}
+		}
+	}
+
+	issueTemplate, templateError := template.ParseFiles(configuration.IssueTemplate)
 	if templateError != nil {
-		log.Fatal(templateError)
+		return templateError
 	}
 	var issuesData []IssueData
+	var report = NewReport()
+	var failures = new(Failures)
 
-	makeLocalDirectory("work")
+	var workflowTemplate *textTemplate.Template
+	if configuration.CreatePullRequest {
+		workflowTemplate, templateError = textTemplate.New("github-workflow.yml").Delims("[[", "]]").ParseFiles("github-workflow.yml")
+		if templateError != nil {
+			return templateError
+		}
+	}
+
+	directoryError := makeLocalDirectory("work")
+	if directoryError != nil {
+		return directoryError
+	}
 	defer removeLocalDirectory("work")
 
+	var jobs []*RepositoryJob
 	for _, repository := range repositories {
 		var existingConfiguredApplication = false
 		if configuration.SkipExistingApplications == true {
 			for _, application := range applications.Applications {
 				if len(application.RepositoryUrl) > 0 &&
-					(application.RepositoryUrl == repository.RepositoryFragment.Url ||
-						application.RepositoryUrl == strings.Replace(repository.RepositoryFragment.Url, "https", "http", 1) ||
-						application.RepositoryUrl == repository.RepositoryFragment.SshUrl) {
+					(application.RepositoryUrl == repository.Url ||
+						application.RepositoryUrl == strings.Replace(repository.Url, "https", "http", 1) ||
+						application.RepositoryUrl == repository.SshUrl) {
 					log.Println("Existing Application Configured, Skipping - " + application.Name + ":" + application.PublicId + ":" + application.RepositoryUrl)
+					reportRow := report.AddRow(repository.NameWithOwner)
+					reportRow.ApplicationId = application.Id
+					reportRow.ApplicationPublicId = application.PublicId
+					reportRow.Status = StatusSkipped
 					existingConfiguredApplication = true
 					break
 				}
@@ -144,104 +552,533 @@ func audit(configuration *AuditConfiguration) {
 			}
 		}
 
-		log.Println("Creating IQ Application - " + repository.RepositoryFragment.Name)
-		var application = iqClient.GetOrCreateApplication(scmOrganization.Id, repository.RepositoryFragment.Name, repository.RepositoryFragment.Name)
-		iqClient.SetApplicationScm(application.Id, repository.RepositoryFragment.Url)
+		job := &RepositoryJob{Repository: repository, ReportRow: report.AddRow(repository.NameWithOwner), Plan: new(Plan)}
+		jobs = append(jobs, job)
+	}
 
-		var dependencies []github.Dependency
-		for _, dependencyGraph := range repository.RepositoryFragment.DependencyGraphManifests.Nodes {
-			dependencies = append(dependencies, dependencyGraph.Dependencies.Nodes...)
+	// Workers only touch their own job, results are collected in repository order once every job has finished
+	runConcurrently(len(jobs), configuration.Concurrency, func(index int) {
+		job := jobs[index]
+		if job.Repository.Error != nil {
+			job.Error = job.Repository.Error
+		} else if configuration.DryRun {
+			job.IssueData, job.Error = planRepository(iqClient, job.Plan, state, organizations, roleMembers, categoryRules, job.Repository, job.ReportRow, workflowTemplate, configuration)
+		} else {
+			job.IssueData, job.Error = auditRepository(configuration, iqClient, scmClient, state, organizations, roleMembers, categoryRules, resolver, workflowTemplate, job.Repository, job.ReportRow)
 		}
+	})
+	for _, job := range jobs {
+		plan.Steps = append(plan.Steps, job.Plan.Steps...)
+		if job.Error != nil {
+			failures.Add(job.ReportRow, job.Error)
+			continue
+		}
+		issuesData = append(issuesData, *job.IssueData)
+	}
 
-		issueData := new(IssueData)
-
-		issueData.IqServerUrl = *configuration.IqServerUrl
-		issueData.Repository = application.PublicId
-		issueData.Contact = *configuration.IqContact
-		issueData.NameWithOwner = repository.RepositoryFragment.NameWithOwner
+	if !configuration.SkipIssueCreation {
+		pullRequestClient, supportsPullRequests := scmClient.(scm.PullRequestClient)
+		if configuration.CreatePullRequest && !supportsPullRequests {
+			log.Println("Pull requests are not supported for " + scmClient.Provider() + ", opening issues instead")
+		}
+		for _, issueData := range issuesData {
+			var openPullRequest = supportsPullRequests && issueData.BuildSystem != nil
+			if configuration.DryRun && openPullRequest {
+				plan.Add(issueData.NameWithOwner, "Open pull request adding " + issueData.BuildSystem.Name + " GitHub Actions workflow " + workflowPath)
+				continue
+			}
+			if configuration.DryRun {
+				plan.Add(issueData.NameWithOwner, "Open " + scmClient.Provider() + " issue - Configure Nexus IQ")
+				continue
+			}
+			reportRow := report.Row(issueData.NameWithOwner)
+			if progress := state.RepositoryProgress(issueData.NameWithOwner); progress.IssueFiled {
+				log.Println("Issue already filed, Skipping - " + issueData.NameWithOwner)
+				reportRow.IssueUrl = progress.IssueUrl
+				continue
+			}
+			var templateBytes bytes.Buffer
+			templateError := issueTemplate.Execute(&templateBytes, issueData)
+			if templateError != nil {
+				failures.Add(reportRow, templateError)
+				continue
+			}
 
-		if dependencies != nil && len(dependencies) > 0 {
-			bom := sbom.NewSbom(dependencies)
-			sbomScanTicket := iqClient.ScanSbom(application.Id, *bom)
+			var issueUrl string
+			var issueError error
+			if openPullRequest {
+				issueUrl, issueError = pullRequestClient.CreatePullRequest(issueData.NameWithOwner, pullRequestBranch, workflowPath, issueData.Workflow, "Configure Nexus IQ", pullRequestHeader + templateBytes.String())
+			} else {
+				issueUrl, issueError = scmClient.CreateIssue(issueData.NameWithOwner, "Configure Nexus IQ", templateBytes.String())
+			}
+			if issueError == scm.ErrIssueSkipped {
+				continue
+			}
+			if issueError != nil {
+				failures.Add(reportRow, issueError)
+				continue
+			}
+			reportRow.IssueUrl = issueUrl
+			// Without a Url the issue cannot be shown on resume, so it is filed again instead
+			if len(issueUrl) == 0 {
+				continue
+			}
+			stateError := state.Update(issueData.NameWithOwner, func(progress *RepositoryProgress) {
+				progress.IssueFiled = true
+				progress.IssueUrl = issueUrl
+			})
+			if stateError != nil {
+				failures.Add(reportRow, stateError)
+			}
+		}
+	}
 
-			sbomScanResult := iqClient.GetSbomScanResult(sbomScanTicket.StatusUrl)
-			issueData.AuditReportUrl = sbomScanResult.ReportHtmlUrl
+	if len(configuration.ReportFile) > 0 {
+		reportError := report.Write(configuration.ReportFile, configuration.ReportFormat)
+		if reportError != nil {
+			return reportError
 		}
+	}
 
-		if !configuration.SkipIQEvaluations {
-			if len(repository.RepositoryFragment.Releases.Nodes) > 0 {
-				release := repository.RepositoryFragment.Releases.Nodes[0]
-				assetDownloadPath := filepath.Join("work", repository.RepositoryFragment.NameWithOwner, "latest-release")
-				makeLocalDirectory(assetDownloadPath)
-				for _, asset := range release.ReleaseAssets.Nodes {
-					assetDownloadLocation := filepath.Join(assetDownloadPath, asset.Name)
-					log.Println("Downloading - " + asset.Name)
-					downloadRelease(*gitHubClient, assetDownloadLocation, asset.Url)
-				}
-				log.Println("Evaluating latest release")
-				evaluationResult := iqClient.Evaluate(assetDownloadPath, application.PublicId, "stage-release")
-				issueData.ReleaseReportUrl = evaluationResult.ReportHtmlUrl
-			}
-
-			if len(repository.RepositoryFragment.Packages.Nodes) > 0 {
-				pkg := repository.RepositoryFragment.Packages.Nodes[0]
-				fileDownloadPath := filepath.Join("work", repository.RepositoryFragment.NameWithOwner, "latest-package")
-				makeLocalDirectory(fileDownloadPath)
-				for _, file := range pkg.LatestVersion.Files.Nodes {
-					fileDownloadLocation := filepath.Join(fileDownloadPath, file.Name)
-					log.Println("Downloading - " + file.Name)
-					downloadRelease(*gitHubClient, fileDownloadLocation, file.Url)
+	if configuration.DryRun {
+		plan.Print()
+	}
+
+	failures.Print()
+	if len(failures.Rows) > 0 {
+		return fmt.Errorf("%v of %v repositories failed", len(failures.Rows), len(report.Rows))
+	}
+	return nil
+}
+
+func planRepository(iqClient *iq.IqClient, plan *Plan, state *State, organizations *OrganizationTree, roleMembers *RoleMembers, categoryRules []CategoryRule, repository scm.Repository, reportRow *ReportRow, workflowTemplate *textTemplate.Template, configuration *AuditConfiguration) (*IssueData, error) {
+	application, planError := planApplication(iqClient, plan, state, organizations, roleMembers, categoryRules, repository, reportRow, configuration)
+	if planError != nil {
+		return nil, planError
+	}
+	reportRow.ApplicationId = application.Id
+	reportRow.ApplicationPublicId = application.PublicId
+	reportRow.Status = StatusExisting
+	if len(application.Id) == 0 {
+		reportRow.Status = StatusPlanned
+	}
+	issueData := &IssueData{Repository: application.PublicId, NameWithOwner: repository.NameWithOwner}
+	if workflowTemplate != nil {
+		issueData.BuildSystem = detectBuildSystem(repository.Manifests)
+	}
+	return issueData, nil
+}
+
+// Stages recorded in the state are not repeated, their results are taken from the state instead.
+func auditRepository(configuration *AuditConfiguration, iqClient *iq.IqClient, scmClient scm.Client, state *State, organizations *OrganizationTree, roleMembers *RoleMembers, categoryRules []CategoryRule, resolver sbom.Resolver, workflowTemplate *textTemplate.Template, repository scm.Repository, reportRow *ReportRow) (*IssueData, error) {
+	progress := state.RepositoryProgress(repository.NameWithOwner)
+	if !progress.ApplicationCreated {
+		organization := organizations.Root
+		if organizations.Mapping != OrganizationMappingNone {
+			// Existing applications stay in their organization, only the organizations of new ones are created
+			existingApplication, getError := iqClient.GetApplication(repository.ApplicationPublicId())
+			if getError != nil {
+				return nil, getError
+			}
+			if existingApplication == nil {
+				var organizationError error
+				organization, organizationError = organizations.Organization(nil, repository)
+				if organizationError != nil {
+					return nil, organizationError
 				}
-				log.Println("Evaluating latest package")
-				evaluationResult := iqClient.Evaluate(fileDownloadPath, application.PublicId, "release")
-				issueData.PackageReportUrl = evaluationResult.ReportHtmlUrl
 			}
 		}
+		log.Println("Creating IQ Application - " + repository.Name)
+		application, created, applicationError := iqClient.GetOrCreateApplication(organization.Id, repository.ApplicationPublicId(), repository.Name)
+		if applicationError != nil {
+			return nil, applicationError
+		}
+		progress.ApplicationCreated = true
+		progress.ApplicationId = application.Id
+		progress.ApplicationPublicId = application.PublicId
+		progress.ApplicationStatus = StatusExisting
+		if created {
+			progress.ApplicationStatus = StatusCreated
+		}
+		stateError := state.Update(repository.NameWithOwner, func(stateProgress *RepositoryProgress) {
+			stateProgress.ApplicationCreated = true
+			stateProgress.ApplicationId = progress.ApplicationId
+			stateProgress.ApplicationPublicId = progress.ApplicationPublicId
+			stateProgress.ApplicationStatus = progress.ApplicationStatus
+		})
+		if stateError != nil {
+			return nil, stateError
+		}
+	}
+	reportRow.ApplicationId = progress.ApplicationId
+	reportRow.ApplicationPublicId = progress.ApplicationPublicId
+	reportRow.Status = progress.ApplicationStatus
 
-		issuesData = append(issuesData, *issueData)
+	if !progress.ScmSet {
+		scmError := iqClient.SetApplicationScm(progress.ApplicationId, scmClient.Provider(), repository.Url)
+		if scmError != nil {
+			return nil, scmError
+		}
+		stateError := state.Update(repository.NameWithOwner, func(stateProgress *RepositoryProgress) {
+			stateProgress.ScmSet = true
+		})
+		if stateError != nil {
+			return nil, stateError
+		}
 	}
 
-	if !configuration.SkipIssueCreation {
-		for _, issueData := range issuesData {
-			var templateBytes bytes.Buffer
-			templateError := issueTemplate.Execute(&templateBytes, issueData)
+	// Access to existing applications is left to their owners
+	if roleMembers != nil && progress.ApplicationStatus == StatusCreated && !progress.RoleMembersAdded {
+		usernames, usernamesError := roleMembers.Usernames(repository)
+		if usernamesError != nil {
+			return nil, usernamesError
+		}
+		for _, username := range usernames {
+			log.Println("Granting IQ role " + roleMembers.Role.Name + " to " + username + " - " + repository.Name)
+			memberError := iqClient.AddApplicationRoleMember(progress.ApplicationId, roleMembers.Role.Id, username)
+			if memberError != nil {
+				return nil, memberError
+			}
+		}
+		stateError := state.Update(repository.NameWithOwner, func(stateProgress *RepositoryProgress) {
+			stateProgress.RoleMembersAdded = true
+		})
+		if stateError != nil {
+			return nil, stateError
+		}
+	}
 
-			if templateError != nil {
-				log.Fatal(templateError)
+	if len(categoryRules) > 0 && !progress.CategoriesApplied {
+		categoriesError := reconcileCategories(iqClient, categoryRules, repository, progress.ApplicationId, progress.ApplicationPublicId)
+		if categoriesError != nil {
+			return nil, categoriesError
+		}
+		stateError := state.Update(repository.NameWithOwner, func(stateProgress *RepositoryProgress) {
+			stateProgress.CategoriesApplied = true
+		})
+		if stateError != nil {
+			return nil, stateError
+		}
+	}
+
+	issueData := new(IssueData)
+
+	issueData.IqServerUrl = *configuration.IqServerUrl
+	issueData.Repository = progress.ApplicationPublicId
+	issueData.Contact = *configuration.IqContact
+	issueData.NameWithOwner = repository.NameWithOwner
+
+	if workflowTemplate != nil {
+		issueData.BuildSystem = detectBuildSystem(repository.Manifests)
+		if issueData.BuildSystem != nil {
+			workflow, workflowError := renderWorkflow(workflowTemplate, issueData.IqServerUrl, issueData.Repository, issueData.BuildSystem)
+			if workflowError != nil {
+				return nil, workflowError
+			}
+			issueData.Workflow = workflow
+		}
+	}
+
+	var dependencies = repository.Dependencies()
+	fingerprint := state.Fingerprint(repository.NameWithOwner)
+	dependencyFingerprint := dependenciesFingerprint(dependencies)
+	releaseFingerprint := assetsFingerprint(repository.ReleaseAssets)
+	packageFingerprint := assetsFingerprint(repository.PackageFiles)
+	sbomChange := changeOf(fingerprint.Dependencies, dependencyFingerprint)
+	releaseChange := changeOf(fingerprint.Release, releaseFingerprint)
+	packageChange := changeOf(fingerprint.Package, packageFingerprint)
+	if configuration.Incremental {
+		reportRow.ScanReason = "dependencies " + sbomChange + ", release " + releaseChange + ", package " + packageChange
+	}
+
+	// SBOM files are written on every run, whether or not the SBOM is scanned
+	scanSbom := len(dependencies) > 0 && !progress.SbomScanned && !(configuration.Incremental && sbomChange == ChangeUnchanged)
+	writeSbom := len(configuration.SbomOutputDir) > 0
+	var bom *sbom.Sbom
+	if scanSbom || writeSbom {
+		var sbomError error
+		bom, sbomError = newRepositorySbom(repository, resolver)
+		if sbomError != nil {
+			return nil, sbomError
+		}
+	}
+	if writeSbom {
+		writeError := writeSbomFiles(configuration, repository, bom)
+		if writeError != nil {
+			return nil, writeError
+		}
+	}
+
+	if len(dependencies) > 0 && !progress.SbomScanned && configuration.Incremental && sbomChange == ChangeUnchanged {
+		log.Println("Dependencies unchanged, Skipping SBOM scan - " + repository.NameWithOwner)
+		progress.SbomPolicyAction = fingerprint.SbomPolicyAction
+		progress.AuditReportUrl = fingerprint.AuditReportUrl
+	} else if scanSbom {
+		sbomScanTicket, scanError := iqClient.ScanSbom(progress.ApplicationId, configuration.SbomStage, bom)
+		if scanError != nil {
+			return nil, scanError
+		}
+		sbomScanResult, resultError := iqClient.GetSbomScanResult(sbomScanTicket.StatusUrl)
+		if resultError != nil {
+			return nil, resultError
+		}
+		progress.SbomPolicyAction = sbomScanResult.PolicyAction
+		progress.AuditReportUrl = sbomScanResult.ReportHtmlUrl
+		stateError := state.Update(repository.NameWithOwner, func(stateProgress *RepositoryProgress) {
+			stateProgress.SbomScanned = true
+			stateProgress.SbomPolicyAction = progress.SbomPolicyAction
+			stateProgress.AuditReportUrl = progress.AuditReportUrl
+		})
+		if stateError == nil {
+			stateError = state.UpdateFingerprint(repository.NameWithOwner, func(stateFingerprint *Fingerprint) {
+				stateFingerprint.Dependencies = dependencyFingerprint
+				stateFingerprint.SbomPolicyAction = progress.SbomPolicyAction
+				stateFingerprint.AuditReportUrl = progress.AuditReportUrl
+			})
+		}
+		if stateError != nil {
+			return nil, stateError
+		}
+	}
+	issueData.AuditReportUrl = progress.AuditReportUrl
+	reportRow.SbomPolicyAction = progress.SbomPolicyAction
+	reportRow.AuditReportUrl = progress.AuditReportUrl
+
+	if !configuration.SkipIQEvaluations {
+		if len(repository.ReleaseAssets) > 0 && !progress.ReleaseEvaluated && configuration.Incremental && releaseChange == ChangeUnchanged {
+			log.Println("Release unchanged, Skipping evaluation - " + repository.NameWithOwner)
+			progress.ReleaseReportUrl = fingerprint.ReleaseReportUrl
+		} else if len(repository.ReleaseAssets) > 0 && !progress.ReleaseEvaluated {
+			log.Println("Evaluating latest release - " + repository.NameWithOwner)
+			evaluationResult, evaluationError := evaluateAssets(configuration.Evaluator, iqClient, scmClient, filepath.Join("work", repository.NameWithOwner, "latest-release"), repository.ReleaseAssets, progress.ApplicationId, progress.ApplicationPublicId, configuration.ReleaseStage)
+			if evaluationError != nil {
+				return nil, evaluationError
+			}
+			progress.ReleaseReportUrl = evaluationResult.ReportHtmlUrl
+			stateError := state.Update(repository.NameWithOwner, func(stateProgress *RepositoryProgress) {
+				stateProgress.ReleaseEvaluated = true
+				stateProgress.ReleaseReportUrl = progress.ReleaseReportUrl
+			})
+			if stateError == nil {
+				stateError = state.UpdateFingerprint(repository.NameWithOwner, func(stateFingerprint *Fingerprint) {
+					stateFingerprint.Release = releaseFingerprint
+					stateFingerprint.ReleaseReportUrl = progress.ReleaseReportUrl
+				})
+			}
+			if stateError != nil {
+				return nil, stateError
+			}
+		}
+
+		if len(repository.PackageFiles) > 0 && !progress.PackageEvaluated && configuration.Incremental && packageChange == ChangeUnchanged {
+			log.Println("Package unchanged, Skipping evaluation - " + repository.NameWithOwner)
+			progress.PackageReportUrl = fingerprint.PackageReportUrl
+		} else if len(repository.PackageFiles) > 0 && !progress.PackageEvaluated {
+			log.Println("Evaluating latest package - " + repository.NameWithOwner)
+			evaluationResult, evaluationError := evaluateAssets(configuration.Evaluator, iqClient, scmClient, filepath.Join("work", repository.NameWithOwner, "latest-package"), repository.PackageFiles, progress.ApplicationId, progress.ApplicationPublicId, configuration.PackageStage)
+			if evaluationError != nil {
+				return nil, evaluationError
+			}
+			progress.PackageReportUrl = evaluationResult.ReportHtmlUrl
+			stateError := state.Update(repository.NameWithOwner, func(stateProgress *RepositoryProgress) {
+				stateProgress.PackageEvaluated = true
+				stateProgress.PackageReportUrl = progress.PackageReportUrl
+			})
+			if stateError == nil {
+				stateError = state.UpdateFingerprint(repository.NameWithOwner, func(stateFingerprint *Fingerprint) {
+					stateFingerprint.Package = packageFingerprint
+					stateFingerprint.PackageReportUrl = progress.PackageReportUrl
+				})
+			}
+			if stateError != nil {
+				return nil, stateError
 			}
+		}
+	}
+	issueData.ReleaseReportUrl = progress.ReleaseReportUrl
+	reportRow.ReleaseReportUrl = progress.ReleaseReportUrl
+	issueData.PackageReportUrl = progress.PackageReportUrl
+	reportRow.PackageReportUrl = progress.PackageReportUrl
+
+	return issueData, nil
+}
 
-			gitHubClient.CreateIssue(issueData.NameWithOwner, "Configure Nexus IQ", templateBytes.String())
+// The Nexus IQ CLI evaluates by public ID while the scan API takes the internal application ID.
+func evaluateAssets(evaluator string, iqClient *iq.IqClient, scmClient scm.Client, downloadPath string, assets []scm.Asset, applicationId string, publicId string, stage string) (*iq.ApplicationEvaluationResult, error) {
+	directoryError := makeLocalDirectory(downloadPath)
+	if directoryError != nil {
+		return nil, directoryError
+	}
+	for _, asset := range assets {
+		log.Println("Downloading - " + asset.Name)
+		downloadError := downloadRelease(scmClient, filepath.Join(downloadPath, asset.Name), asset.Url)
+		if downloadError != nil {
+			return nil, downloadError
 		}
 	}
+	if evaluator == EvaluatorNative {
+		return iqClient.EvaluateFiles(downloadPath, applicationId, stage)
+	}
+	return iqClient.Evaluate(downloadPath, publicId, stage)
 }
 
-func makeLocalDirectory(directory string) {
-	// https://github.com/golang/go/issues/22323
-	errorMakeDir := os.MkdirAll("." + string(filepath.Separator) + directory, 0700)
-	if errorMakeDir != nil {
-		log.Fatal(errorMakeDir)
+func planOrganization(iqClient *iq.IqClient, plan *Plan, organizationName string) (*iq.Organization, error) {
+	organization, getError := iqClient.GetOrganization(organizationName, "")
+	if getError != nil {
+		return nil, getError
 	}
+	if organization == nil {
+		plan.Add("IQ Organization " + organizationName, "Create IQ Organization")
+		organization = new(iq.Organization)
+		organization.Name = organizationName
+	}
+	return organization, nil
+}
+
+func planOrganizationScm(iqClient *iq.IqClient, plan *Plan, organization *iq.Organization, scmClient scm.Client) error {
+	target := "IQ Organization " + organization.Name
+	if len(organization.Id) > 0 {
+		organizationScm, getError := iqClient.GetOrganizationScm(organization.Id)
+		if getError != nil {
+			return getError
+		}
+		if strings.EqualFold(organizationScm.Provider, scmClient.Provider()) && organizationScm.BaseUrl == scmClient.ServerUrl() {
+			plan.Add(target, "Refresh source control token for provider " + scmClient.Provider())
+			return nil
+		}
+		if len(organizationScm.Provider) > 0 {
+			plan.Add(target, "Change source control provider from " + organizationScm.Provider + " to " + scmClient.Provider())
+			return nil
+		}
+	}
+	plan.Add(target, "Configure source control provider " + scmClient.Provider())
+	return nil
+}
+
+func planApplication(iqClient *iq.IqClient, plan *Plan, state *State, organizations *OrganizationTree, roleMembers *RoleMembers, categoryRules []CategoryRule, repository scm.Repository, reportRow *ReportRow, configuration *AuditConfiguration) (*iq.Application, error) {
+	target := repository.NameWithOwner
+	application, getError := iqClient.GetApplication(repository.ApplicationPublicId())
+	if getError != nil {
+		return nil, getError
+	}
+	var currentRepositoryUrl string
+	if application == nil {
+		organization, organizationError := organizations.Organization(plan, repository)
+		if organizationError != nil {
+			return nil, organizationError
+		}
+		plan.Add(target, "Create IQ Application " + repository.Name + " in IQ Organization " + organization.Name)
+		if roleMembers != nil {
+			usernames, usernamesError := roleMembers.Usernames(repository)
+			if usernamesError != nil {
+				return nil, usernamesError
+			}
+			if len(usernames) > 0 {
+				plan.Add(target, "Grant IQ role " + roleMembers.Role.Name + " to " + strings.Join(usernames, ", "))
+			}
+		}
+		application = new(iq.Application)
+		application.PublicId = repository.ApplicationPublicId()
+		application.Name = repository.Name
+	} else {
+		applicationScm, scmError := iqClient.GetApplicationScm(application.Id)
+		if scmError != nil {
+			return nil, scmError
+		}
+		currentRepositoryUrl = applicationScm.RepositoryUrl
+	}
+	if len(categoryRules) > 0 && len(application.Id) == 0 {
+		categories := ruleCategories(categoryRules, repository)
+		if len(categories) > 0 {
+			plan.Add(target, "Apply IQ Application Categories " + strings.Join(categories, ", "))
+		}
+	} else if len(categoryRules) > 0 {
+		categories, categoriesError := iqClient.GetApplicationCategories(application.PublicId)
+		if categoriesError != nil {
+			return nil, categoriesError
+		}
+		tagIds, tagsError := iqClient.GetApplicationTagIds(application.Id)
+		if tagsError != nil {
+			return nil, tagsError
+		}
+		changes := categoryChanges(categoryRules, repository, categories, tagIds)
+		if len(changes.Added) > 0 {
+			plan.Add(target, "Add IQ Application Categories " + strings.Join(changes.Added, ", "))
+		}
+		if len(changes.Removed) > 0 {
+			plan.Add(target, "Remove IQ Application Categories " + strings.Join(changes.Removed, ", "))
+		}
+	}
+	if currentRepositoryUrl != repository.Url {
+		if len(currentRepositoryUrl) > 0 {
+			plan.Add(target, "Change IQ Application source control repository from " + currentRepositoryUrl + " to " + repository.Url)
+		} else {
+			plan.Add(target, "Configure IQ Application source control repository " + repository.Url)
+		}
+	}
+
+	dependencies := repository.Dependencies()
+	fingerprint := state.Fingerprint(repository.NameWithOwner)
+	sbomChange := changeOf(fingerprint.Dependencies, dependenciesFingerprint(dependencies))
+	releaseChange := changeOf(fingerprint.Release, assetsFingerprint(repository.ReleaseAssets))
+	packageChange := changeOf(fingerprint.Package, assetsFingerprint(repository.PackageFiles))
+	if configuration.Incremental {
+		reportRow.ScanReason = "dependencies " + sbomChange + ", release " + releaseChange + ", package " + packageChange
+	}
+	if len(dependencies) > 0 && !(configuration.Incremental && sbomChange == ChangeUnchanged) {
+		plan.Add(target, fmt.Sprintf("Scan SBOM of %v reported dependencies", len(dependencies)))
+	}
+	if !configuration.SkipIQEvaluations {
+		if len(repository.ReleaseAssets) > 0 && !(configuration.Incremental && releaseChange == ChangeUnchanged) {
+			plan.Add(target, fmt.Sprintf("Evaluate %v latest release assets %v at %v", len(repository.ReleaseAssets), evaluatorDescription(configuration.Evaluator), configuration.ReleaseStage))
+		}
+		if len(repository.PackageFiles) > 0 && !(configuration.Incremental && packageChange == ChangeUnchanged) {
+			plan.Add(target, fmt.Sprintf("Evaluate %v latest package files %v at %v", len(repository.PackageFiles), evaluatorDescription(configuration.Evaluator), configuration.PackageStage))
+		}
+	}
+	return application, nil
+}
+
+func defaultCacheDirectory() string {
+	cacheDirectory, cacheError := os.UserCacheDir()
+	if cacheError != nil {
+		return filepath.Join(".", ".iq-scm-audit-cache")
+	}
+	return filepath.Join(cacheDirectory, "iq-scm-audit")
+}
+
+func evaluatorDescription(evaluator string) string {
+	if evaluator == EvaluatorNative {
+		return "by SHA-1 fingerprint through the IQ scan API"
+	}
+	return "with the Nexus IQ CLI"
+}
+
+func makeLocalDirectory(directory string) error {
+	// https://github.com/golang/go/issues/22323
+	return os.MkdirAll("." + string(filepath.Separator) + directory, 0700)
 }
 
 func removeLocalDirectory(directory string) {
 	errorRemoveDir := os.RemoveAll("." + string(filepath.Separator) + directory)
 	if errorRemoveDir != nil {
-		log.Fatal(errorRemoveDir)
+		log.Println("Unable to remove local directory - " + errorRemoveDir.Error())
 	}
 }
 
-func downloadRelease(gitHubClient github.GitHubClient, path string, url string) {
-	releaseBytes := gitHubClient.DownloadRelease(url)
+func downloadRelease(scmClient scm.Client, path string, url string) error {
+	releaseBytes, downloadError := scmClient.DownloadRelease(url)
+	if downloadError != nil {
+		return downloadError
+	}
 	downloadFile, createError := os.Create(path)
 	if createError != nil {
-		log.Fatal(createError)
+		return createError
 	}
 	_, copyError := io.Copy(downloadFile, bytes.NewBuffer(releaseBytes))
 	if copyError != nil {
-		log.Fatal(copyError)
-	}
-	closeError := downloadFile.Close()
-	if closeError != nil {
-		log.Fatal(closeError)
+		_ = downloadFile.Close()
+		return copyError
 	}
-}
\ No newline at end of file
+	return downloadFile.Close()
+}
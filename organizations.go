@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"iq-scm-audit/iq"
+	"iq-scm-audit/scm"
+	"log"
+	"strings"
+	"sync"
+)
+
+const (
+	OrganizationMappingNone = "none"
+	OrganizationMappingOwner = "owner"
+	OrganizationMappingTeam = "team"
+)
+
+// OrganizationTree places new applications in IQ child organizations of the root organization named after the owner of
+// their repository, and the team owning it within that. Organizations are shared by every worker, so they are looked up
+// or created once.
+type OrganizationTree struct {
+	Root *iq.Organization
+	Mapping string
+	iqClient *iq.IqClient
+	teamClient scm.TeamClient
+	dryRun bool
+	organizations map[string]*iq.Organization
+	lock sync.Mutex
+}
+
+func NewOrganizationTree(iqClient *iq.IqClient, scmClient scm.Client, root *iq.Organization, mapping string, dryRun bool) (*OrganizationTree, error) {
+	switch mapping {
+	case OrganizationMappingNone, OrganizationMappingOwner:
+	case OrganizationMappingTeam:
+		if _, supportsTeams := scmClient.(scm.TeamClient); !supportsTeams {
+			log.Println("Owning teams are not supported for " + scmClient.Provider() + ", mapping IQ Organizations by owner instead")
+			mapping = OrganizationMappingOwner
+		}
+	default:
+		return nil, errors.New("unsupported IQ Organization mapping - " + mapping)
+	}
+	tree := &OrganizationTree{Root: root, Mapping: mapping, iqClient: iqClient, dryRun: dryRun}
+	tree.teamClient, _ = scmClient.(scm.TeamClient)
+	tree.organizations = make(map[string]*iq.Organization)
+	return tree, nil
+}
+
+// Organization returns the organization a new application for the repository belongs in. Organizations that would be
+// created are added to the plan on a dry run and returned without an Id.
+func (tree *OrganizationTree) Organization(plan *Plan, repository scm.Repository) (*iq.Organization, error) {
+	if tree.Mapping == OrganizationMappingNone {
+		return tree.Root, nil
+	}
+	path := []string{repositoryOwner(repository.NameWithOwner)}
+	if tree.Mapping == OrganizationMappingTeam {
+		team, teamError := tree.teamClient.OwningTeam(repository.NameWithOwner)
+		if teamError != nil {
+			return nil, teamError
+		}
+		if len(team) > 0 {
+			path = append(path, team)
+		}
+	}
+
+	tree.lock.Lock()
+	defer tree.lock.Unlock()
+	organization := tree.Root
+	for depth, name := range path {
+		key := strings.Join(path[:depth + 1], "/")
+		child, found := tree.organizations[key]
+		if !found {
+			var childError error
+			child, childError = tree.child(plan, organization, name)
+			if childError != nil {
+				return nil, childError
+			}
+			tree.organizations[key] = child
+		}
+		organization = child
+	}
+	return organization, nil
+}
+
+func (tree *OrganizationTree) child(plan *Plan, parent *iq.Organization, name string) (*iq.Organization, error) {
+	if !tree.dryRun {
+		log.Println("Getting or Creating IQ Organization - " + name + " in " + parent.Name)
+		return tree.iqClient.GetOrCreateOrganization(name, parent.Id)
+	}
+	if len(parent.Id) > 0 {
+		organization, getError := tree.iqClient.GetOrganization(name, parent.Id)
+		if getError != nil || organization != nil {
+			return organization, getError
+		}
+	}
+	plan.Add("IQ Organization " + name, "Create IQ Organization in IQ Organization " + parent.Name)
+	return &iq.Organization{Name: name, ParentOrganizationId: parent.Id}, nil
+}
+
+// GitLab subgroups keep their full path as the owner
+func repositoryOwner(nameWithOwner string) string {
+	separator := strings.LastIndex(nameWithOwner, "/")
+	if separator < 0 {
+		return nameWithOwner
+	}
+	return nameWithOwner[:separator]
+}
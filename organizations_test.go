@@ -0,0 +1,81 @@
+package main
+
+import (
+	"iq-scm-audit/iq"
+	"iq-scm-audit/scm"
+	"strings"
+	"testing"
+)
+
+type testTeamClient map[string]string
+
+func (teams testTeamClient) OwningTeam(repositoryNameWithOwner string) (string, error) {
+	return teams[repositoryNameWithOwner], nil
+}
+
+func TestRepositoryOwner(t *testing.T) {
+	tests := []struct {
+		nameWithOwner string
+		owner string
+	}{
+		{"owner/repository", "owner"},
+		{"group/subgroup/project", "group/subgroup"},
+		{"repository", "repository"},
+	}
+	for _, test := range tests {
+		if owner := repositoryOwner(test.nameWithOwner); owner != test.owner {
+			t.Errorf("repositoryOwner(%q) = %q, want %q", test.nameWithOwner, owner, test.owner)
+		}
+	}
+}
+
+func TestOrganizationTree(t *testing.T) {
+	teams := testTeamClient{"owner/one": "platform", "owner/two": "platform", "owner/three": "", "other/four": "web"}
+	tests := []struct {
+		mapping string
+		repository string
+		organization string
+		parent string
+	}{
+		{OrganizationMappingNone, "owner/one", "Root", ""},
+		{OrganizationMappingOwner, "owner/one", "owner", ""},
+		{OrganizationMappingOwner, "group/subgroup/project", "group/subgroup", ""},
+		{OrganizationMappingTeam, "owner/one", "platform", "owner"},
+		{OrganizationMappingTeam, "owner/three", "owner", ""},
+		{OrganizationMappingTeam, "other/four", "web", "other"},
+	}
+	for _, test := range tests {
+		tree := &OrganizationTree{Root: &iq.Organization{Name: "Root"}, Mapping: test.mapping, teamClient: teams, dryRun: true, organizations: make(map[string]*iq.Organization)}
+		plan := new(Plan)
+		organization, organizationError := tree.Organization(plan, scm.Repository{NameWithOwner: test.repository})
+		if organizationError != nil {
+			t.Errorf("Organization(%v, %q) returned %v", test.mapping, test.repository, organizationError)
+			continue
+		}
+		if organization.Name != test.organization {
+			t.Errorf("Organization(%v, %q) = %q, want %q", test.mapping, test.repository, organization.Name, test.organization)
+		}
+		if parent := tree.organizations[repositoryOwner(test.repository)]; test.parent != "" && (parent == nil || parent.Name != test.parent) {
+			t.Errorf("Organization(%v, %q) parent = %v, want %q", test.mapping, test.repository, parent, test.parent)
+		}
+	}
+}
+
+func TestOrganizationTreeCreatesOrganizationsOnce(t *testing.T) {
+	teams := testTeamClient{"owner/one": "platform", "owner/two": "platform", "owner/three": "web"}
+	tree := &OrganizationTree{Root: &iq.Organization{Name: "Root"}, Mapping: OrganizationMappingTeam, teamClient: teams, dryRun: true, organizations: make(map[string]*iq.Organization)}
+	plan := new(Plan)
+	for _, repository := range []string{"owner/one", "owner/two", "owner/three"} {
+		if _, organizationError := tree.Organization(plan, scm.Repository{NameWithOwner: repository}); organizationError != nil {
+			t.Fatal(organizationError)
+		}
+	}
+	var created []string
+	for _, step := range plan.Steps {
+		created = append(created, step.Target)
+	}
+	want := "IQ Organization owner,IQ Organization platform,IQ Organization web"
+	if got := strings.Join(created, ","); got != want {
+		t.Errorf("planned organizations = %v, want %v", got, want)
+	}
+}
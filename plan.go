@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type Plan struct {
+	Steps []PlanStep
+}
+
+type PlanStep struct {
+	Target string
+	Action string
+}
+
+func (plan *Plan) Add(target string, action string) {
+	plan.Steps = append(plan.Steps, PlanStep{Target: target, Action: action})
+}
+
+func (plan *Plan) Print() {
+	_, _ = fmt.Fprint(os.Stdout, "\nDry Run Plan - no changes were made to IQ or source control\n")
+	if len(plan.Steps) == 0 {
+		_, _ = fmt.Fprint(os.Stdout, "\nNo changes\n")
+		return
+	}
+	var targets []string
+	actions := make(map[string][]string)
+	for _, step := range plan.Steps {
+		if _, found := actions[step.Target]; !found {
+			targets = append(targets, step.Target)
+		}
+		actions[step.Target] = append(actions[step.Target], step.Action)
+	}
+	for _, target := range targets {
+		_, _ = fmt.Fprint(os.Stdout, "\n"+target+"\n")
+		for _, action := range actions[target] {
+			_, _ = fmt.Fprint(os.Stdout, "  - "+action+"\n")
+		}
+	}
+}
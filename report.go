@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	StatusCreated = "created"
+	StatusExisting = "existing"
+	StatusSkipped = "skipped"
+	StatusPlanned = "planned"
+	StatusGenerated = "generated"
+	StatusFailed = "failed"
+)
+
+type Report struct {
+	Rows []*ReportRow
+	rowsByRepository map[string]*ReportRow
+}
+
+type ReportRow struct {
+	Repository string `json:"repository"`
+	ApplicationId string `json:"applicationId"`
+	ApplicationPublicId string `json:"applicationPublicId"`
+	Status string `json:"status"`
+	SbomPolicyAction string `json:"sbomPolicyAction"`
+	AuditReportUrl string `json:"auditReportUrl"`
+	ReleaseReportUrl string `json:"releaseReportUrl"`
+	PackageReportUrl string `json:"packageReportUrl"`
+	IssueUrl string `json:"issueUrl"`
+	ScanReason string `json:"scanReason"`
+	Error string `json:"error"`
+}
+
+var reportColumns = []string{"repository", "applicationId", "applicationPublicId", "status", "sbomPolicyAction",
+	"auditReportUrl", "releaseReportUrl", "packageReportUrl", "issueUrl", "scanReason", "error"}
+
+func NewReport() *Report {
+	report := new(Report)
+	report.rowsByRepository = make(map[string]*ReportRow)
+	return report
+}
+
+func (report *Report) AddRow(repository string) *ReportRow {
+	row := new(ReportRow)
+	row.Repository = repository
+	report.Rows = append(report.Rows, row)
+	report.rowsByRepository[repository] = row
+	return row
+}
+
+func (report *Report) Row(repository string) *ReportRow {
+	row, found := report.rowsByRepository[repository]
+	if !found {
+		return report.AddRow(repository)
+	}
+	return row
+}
+
+// The format is json or csv, when empty it is taken from the file extension.
+func (report *Report) Write(path string, format string) error {
+	if len(format) == 0 {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	var writeError error
+	switch format {
+	case "csv":
+		writeError = report.writeCsv(path)
+	case "json":
+		writeError = report.writeJson(path)
+	default:
+		return errors.New("unsupported report format - " + format)
+	}
+	if writeError != nil {
+		return writeError
+	}
+	log.Println("Wrote report - " + path)
+	return nil
+}
+
+func (report *Report) writeJson(path string) error {
+	rows := report.Rows
+	if rows == nil {
+		rows = []*ReportRow{}
+	}
+	jsonBytes, marshalError := json.MarshalIndent(rows, "", "  ")
+	if marshalError != nil {
+		return marshalError
+	}
+	return ioutil.WriteFile(path, jsonBytes, 0600)
+}
+
+func (report *Report) writeCsv(path string) error {
+	reportFile, createError := os.Create(path)
+	if createError != nil {
+		return createError
+	}
+	defer reportFile.Close()
+
+	writer := csv.NewWriter(reportFile)
+	records := [][]string{reportColumns}
+	for _, row := range report.Rows {
+		records = append(records, []string{row.Repository, row.ApplicationId, row.ApplicationPublicId, row.Status,
+			row.SbomPolicyAction, row.AuditReportUrl, row.ReleaseReportUrl, row.PackageReportUrl, row.IssueUrl, row.ScanReason, row.Error})
+	}
+	return writer.WriteAll(records)
+}
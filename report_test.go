@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestReport() *Report {
+	report := NewReport()
+	created := report.AddRow("owner/one")
+	created.ApplicationPublicId = "one"
+	created.Status = StatusCreated
+	created.IssueUrl = "https://github.com/owner/one/issues/1"
+	failed := report.AddRow("owner/two")
+	failed.Status = StatusFailed
+	failed.Error = "dependency graph unavailable, \"retry\""
+	return report
+}
+
+func TestReportRow(t *testing.T) {
+	report := newTestReport()
+	if row := report.Row("owner/one"); row != report.Rows[0] {
+		t.Errorf("Row(owner/one) = %v, want the existing row", row)
+	}
+	if row := report.Row("owner/three"); row.Repository != "owner/three" || len(report.Rows) != 3 {
+		t.Errorf("Row(owner/three) = %v with %v rows, want a new row", row, len(report.Rows))
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	directory, directoryError := ioutil.TempDir("", "report")
+	if directoryError != nil {
+		t.Fatal(directoryError)
+	}
+	defer os.RemoveAll(directory)
+	report := newTestReport()
+
+	csvPath := filepath.Join(directory, "report.csv")
+	if writeError := report.Write(csvPath, ""); writeError != nil {
+		t.Fatal(writeError)
+	}
+	csvBytes, _ := ioutil.ReadFile(csvPath)
+	lines := strings.Split(strings.TrimSpace(string(csvBytes)), "\n")
+	want := []string{
+		strings.Join(reportColumns, ","),
+		"owner/one,,one,created,,,,,https://github.com/owner/one/issues/1,,",
+		"owner/two,,,failed,,,,,,,\"dependency graph unavailable, \"\"retry\"\"\"",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Write(csv) = %v, want %v", lines, want)
+	}
+
+	jsonPath := filepath.Join(directory, "report.out")
+	if writeError := report.Write(jsonPath, "json"); writeError != nil {
+		t.Fatal(writeError)
+	}
+	jsonBytes, _ := ioutil.ReadFile(jsonPath)
+	var rows []map[string]string
+	if unmarshalError := json.Unmarshal(jsonBytes, &rows); unmarshalError != nil {
+		t.Fatal(unmarshalError)
+	}
+	if len(rows) != 2 || rows[0]["repository"] != "owner/one" || rows[0]["status"] != StatusCreated || rows[1]["error"] != "dependency graph unavailable, \"retry\"" {
+		t.Errorf("Write(json) = %v", rows)
+	}
+	if len(rows[0]) != len(reportColumns) {
+		t.Errorf("Write(json) has %v fields, want the %v report columns", len(rows[0]), len(reportColumns))
+	}
+
+	if writeError := report.Write(filepath.Join(directory, "report.xml"), ""); writeError == nil {
+		t.Errorf("Write(xml) returned no error")
+	}
+}
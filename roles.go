@@ -0,0 +1,71 @@
+package main
+
+import (
+	"iq-scm-audit/iq"
+	"iq-scm-audit/scm"
+	"log"
+	"strings"
+)
+
+// RoleMembers grants the maintainers of a repository a role on its new IQ Application. IQ users are read once and
+// matched to maintainers by email, then by username against their source control login.
+type RoleMembers struct {
+	Role *iq.Role
+	users []iq.User
+	maintainerClient scm.MaintainerClient
+}
+
+// NewRoleMembers returns nil when no role is granted or the provider cannot list maintainers.
+func NewRoleMembers(iqClient *iq.IqClient, scmClient scm.Client, roleName string) (*RoleMembers, error) {
+	if len(roleName) == 0 {
+		return nil, nil
+	}
+	maintainerClient, supportsMaintainers := scmClient.(scm.MaintainerClient)
+	if !supportsMaintainers {
+		log.Println("Repository maintainers are not supported for " + scmClient.Provider() + ", Skipping IQ role " + roleName)
+		return nil, nil
+	}
+	role, roleError := iqClient.GetRole(roleName)
+	if roleError != nil {
+		return nil, roleError
+	}
+	users, usersError := iqClient.GetUsers()
+	if usersError != nil {
+		return nil, usersError
+	}
+	return &RoleMembers{Role: role, users: users, maintainerClient: maintainerClient}, nil
+}
+
+// Usernames returns the IQ users of the repository maintainers, maintainers without an IQ user are logged and left out.
+func (members *RoleMembers) Usernames(repository scm.Repository) ([]string, error) {
+	maintainers, maintainersError := members.maintainerClient.Maintainers(repository.NameWithOwner)
+	if maintainersError != nil {
+		return nil, maintainersError
+	}
+	var usernames []string
+	for _, maintainer := range maintainers {
+		user := members.user(maintainer)
+		if user == nil {
+			log.Println("No IQ user for maintainer " + maintainer.Login + ", Skipping - " + repository.NameWithOwner)
+			continue
+		}
+		usernames = append(usernames, user.Username)
+	}
+	return usernames, nil
+}
+
+func (members *RoleMembers) user(maintainer scm.Member) *iq.User {
+	if len(maintainer.Email) > 0 {
+		for index, user := range members.users {
+			if strings.EqualFold(user.Email, maintainer.Email) {
+				return &members.users[index]
+			}
+		}
+	}
+	for index, user := range members.users {
+		if strings.EqualFold(user.Username, maintainer.Login) {
+			return &members.users[index]
+		}
+	}
+	return nil
+}
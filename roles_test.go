@@ -0,0 +1,51 @@
+package main
+
+import (
+	"iq-scm-audit/iq"
+	"iq-scm-audit/scm"
+	"strings"
+	"testing"
+)
+
+type testMaintainerClient map[string][]scm.Member
+
+func (maintainers testMaintainerClient) Maintainers(repositoryNameWithOwner string) ([]scm.Member, error) {
+	return maintainers[repositoryNameWithOwner], nil
+}
+
+func TestRoleMembersUsernames(t *testing.T) {
+	members := &RoleMembers{
+		Role: &iq.Role{Name: "Owner"},
+		users: []iq.User{
+			{Username: "ada", Email: "ada@example.com"},
+			{Username: "Grace", Email: "grace.hopper@example.com"},
+			{Username: "linus", Email: ""},
+		},
+		maintainerClient: testMaintainerClient{
+			"owner/repository": {
+				{Login: "ada-gh", Email: "ADA@example.com"},
+				{Login: "grace", Email: "grace@personal.example"},
+				{Login: "torvalds", Email: ""},
+				{Login: "linus", Email: ""},
+			},
+		},
+	}
+	tests := []struct {
+		repository string
+		usernames string
+	}{
+		// Email first, then the login against the username, maintainers without an IQ user are left out
+		{"owner/repository", "ada,Grace,linus"},
+		{"owner/unmaintained", ""},
+	}
+	for _, test := range tests {
+		usernames, usernamesError := members.Usernames(scm.Repository{NameWithOwner: test.repository})
+		if usernamesError != nil {
+			t.Errorf("Usernames(%q) returned %v", test.repository, usernamesError)
+			continue
+		}
+		if strings.Join(usernames, ",") != test.usernames {
+			t.Errorf("Usernames(%q) = %v, want %v", test.repository, usernames, test.usernames)
+		}
+	}
+}
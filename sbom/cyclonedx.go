@@ -0,0 +1,199 @@
+package sbom
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	FormatXml = "xml"
+	FormatJson = "json"
+)
+
+// CycloneDX spec versions from oldest to newest
+var SpecVersions = []string{"1.1", "1.2", "1.3", "1.4", "1.5"}
+
+type cycloneDxBom struct {
+	XMLName xml.Name `xml:"bom" json:"-"`
+	XMLNs string `xml:"xmlns,attr" json:"-"`
+	BomFormat string `xml:"-" json:"bomFormat"`
+	SpecVersion string `xml:"-" json:"specVersion"`
+	SerialNumber string `xml:"serialNumber,attr" json:"serialNumber"`
+	Version int `xml:"version,attr" json:"version"`
+	Metadata *cycloneDxMetadata `xml:"metadata,omitempty" json:"metadata,omitempty"`
+	Components []cycloneDxComponent `xml:"components>component" json:"components"`
+	Dependencies *cycloneDxDependencies `xml:"dependencies,omitempty" json:"dependencies,omitempty"`
+}
+
+type cycloneDxMetadata struct {
+	Timestamp string `xml:"timestamp" json:"timestamp"`
+	Tools []cycloneDxTool `xml:"tools>tool" json:"tools"`
+	Component *cycloneDxComponent `xml:"component,omitempty" json:"component,omitempty"`
+}
+
+type cycloneDxTool struct {
+	Name string `xml:"name" json:"name"`
+}
+
+// Elements are in the order of the XML schema
+type cycloneDxComponent struct {
+	BomRef string `xml:"bom-ref,attr,omitempty" json:"bom-ref,omitempty"`
+	Type string `xml:"type,attr" json:"type"`
+	Group string `xml:"group,omitempty" json:"group,omitempty"`
+	Name string `xml:"name" json:"name"`
+	// Required until 1.4, nil leaves it out
+	Version *string `xml:"version" json:"version,omitempty"`
+	Hashes *cycloneDxHashes `xml:"hashes,omitempty" json:"hashes,omitempty"`
+	Purl string `xml:"purl,omitempty" json:"purl,omitempty"`
+	Properties *cycloneDxProperties `xml:"properties,omitempty" json:"properties,omitempty"`
+}
+
+// encoding/xml writes the wrapper of an a>b path even when the list is empty, lists that are left out when empty are
+// wrapped in a pointer that JSON encodes as the list itself
+type cycloneDxHashes struct {
+	Hash []cycloneDxHash `xml:"hash"`
+}
+
+type cycloneDxProperties struct {
+	Property []cycloneDxProperty `xml:"property"`
+}
+
+type cycloneDxDependencies struct {
+	Dependency []cycloneDxDependency `xml:"dependency"`
+}
+
+type cycloneDxHash struct {
+	Alg string `xml:"alg,attr" json:"alg"`
+	Content string `xml:",chardata" json:"content"`
+}
+
+type cycloneDxProperty struct {
+	Name string `xml:"name,attr" json:"name"`
+	Value string `xml:",chardata" json:"value"`
+}
+
+// XML nests the dependencies of a component as dependency elements, JSON lists their refs in dependsOn
+type cycloneDxDependency struct {
+	Ref string `xml:"ref,attr" json:"ref"`
+	Dependencies []cycloneDxDependency `xml:"dependency,omitempty" json:"-"`
+	DependsOn []string `xml:"-" json:"dependsOn,omitempty"`
+}
+
+// IsSpecVersion reports whether the CycloneDX spec version can be encoded.
+func IsSpecVersion(specVersion string) bool {
+	return specVersionIndex(specVersion) >= 0
+}
+
+// Encode writes the SBOM in a CycloneDX spec version as xml or json, leaving out what the version does not support.
+// Metadata and the dependency graph need 1.2, as does JSON, and properties need 1.3.
+func (sbom *Sbom) Encode(specVersion string, format string) ([]byte, error) {
+	index := specVersionIndex(specVersion)
+	if index < 0 {
+		return nil, errors.New("unsupported CycloneDX spec version, use one of " + strings.Join(SpecVersions, ", ") + " - " + specVersion)
+	}
+	supportsMetadata := index >= specVersionIndex("1.2")
+	supportsProperties := index >= specVersionIndex("1.3")
+	optionalVersion := index >= specVersionIndex("1.4")
+
+	bom := cycloneDxBom{
+		XMLNs: "http://cyclonedx.org/schema/bom/" + specVersion,
+		BomFormat: "CycloneDX",
+		SpecVersion: specVersion,
+		SerialNumber: sbom.SerialNumber,
+		Version: sbom.Version,
+		Components: []cycloneDxComponent{},
+	}
+	for _, component := range sbom.Components {
+		bom.Components = append(bom.Components, component.toCycloneDx(supportsProperties, optionalVersion))
+	}
+	if supportsMetadata {
+		bom.Metadata = &cycloneDxMetadata{
+			Timestamp: sbom.Metadata.Timestamp.Format(time.RFC3339),
+			Tools: []cycloneDxTool{{Name: ToolName}},
+		}
+		if sbom.Metadata.Component != nil {
+			subject := sbom.Metadata.Component.toCycloneDx(supportsProperties, optionalVersion)
+			bom.Metadata.Component = &subject
+			dependency := cycloneDxDependency{Ref: subject.BomRef}
+			for _, component := range bom.Components {
+				dependency.Dependencies = append(dependency.Dependencies, cycloneDxDependency{Ref: component.BomRef})
+				dependency.DependsOn = append(dependency.DependsOn, component.BomRef)
+			}
+			bom.Dependencies = &cycloneDxDependencies{Dependency: []cycloneDxDependency{dependency}}
+		}
+	}
+
+	switch format {
+	case FormatXml:
+		xmlBytes, marshalError := xml.MarshalIndent(bom, "", "  ")
+		if marshalError != nil {
+			return nil, marshalError
+		}
+		return append([]byte(xml.Header), xmlBytes...), nil
+	case FormatJson:
+		if !supportsMetadata {
+			return nil, errors.New("CycloneDX JSON needs spec version 1.2 or later - " + specVersion)
+		}
+		return json.MarshalIndent(bom, "", "  ")
+	}
+	return nil, errors.New("unsupported CycloneDX format, use xml or json - " + format)
+}
+
+// ContentType is the media type of an SBOM encoded in the format.
+func ContentType(format string) string {
+	if format == FormatJson {
+		return "application/json"
+	}
+	return "application/xml"
+}
+
+func (component Component) toCycloneDx(supportsProperties bool, optionalVersion bool) cycloneDxComponent {
+	cycloneDxComponent := cycloneDxComponent{
+		BomRef: component.BomRef,
+		Type: component.Type,
+		Group: component.Group,
+		Name: component.Name,
+		Purl: component.Purl,
+	}
+	if len(component.Version) > 0 || !optionalVersion {
+		version := component.Version
+		cycloneDxComponent.Version = &version
+	}
+	if len(component.Hashes) > 0 {
+		cycloneDxComponent.Hashes = new(cycloneDxHashes)
+		for _, hash := range component.Hashes {
+			cycloneDxComponent.Hashes.Hash = append(cycloneDxComponent.Hashes.Hash, cycloneDxHash{Alg: hash.Alg, Content: hash.Value})
+		}
+	}
+	if supportsProperties && len(component.Properties) > 0 {
+		cycloneDxComponent.Properties = new(cycloneDxProperties)
+		for _, property := range component.Properties {
+			cycloneDxComponent.Properties.Property = append(cycloneDxComponent.Properties.Property, cycloneDxProperty{Name: property.Name, Value: property.Value})
+		}
+	}
+	return cycloneDxComponent
+}
+
+func (hashes *cycloneDxHashes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hashes.Hash)
+}
+
+func (properties *cycloneDxProperties) MarshalJSON() ([]byte, error) {
+	return json.Marshal(properties.Property)
+}
+
+func (dependencies *cycloneDxDependencies) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dependencies.Dependency)
+}
+
+func specVersionIndex(specVersion string) int {
+	for index, supported := range SpecVersions {
+		if supported == specVersion {
+			return index
+		}
+	}
+	return -1
+}
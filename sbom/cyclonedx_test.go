@@ -0,0 +1,162 @@
+package sbom
+
+import (
+	"encoding/json"
+	"iq-scm-audit/scm"
+	"strings"
+	"testing"
+)
+
+func newTestSbom(t *testing.T) *Sbom {
+	repository := scm.Repository{Name: "repository", NameWithOwner: "owner/repository", Manifests: []scm.Manifest{
+		{Filename: "package.json", Dependencies: []scm.Dependency{
+			{PackageManager: "NPM", PackageName: "lodash", Requirements: "= 4.17.21"},
+		}},
+		{Filename: "web/package.json", Dependencies: []scm.Dependency{
+			{PackageManager: "NPM", PackageName: "lodash", Requirements: "= 4.17.21"},
+			{PackageManager: "NPM", PackageName: "react", Requirements: "= 18.2.0"},
+		}},
+	}}
+	bom, sbomError := NewSbom(repository, nil)
+	if sbomError != nil {
+		t.Fatal(sbomError)
+	}
+	return bom
+}
+
+func TestEncodeXml(t *testing.T) {
+	bom := newTestSbom(t)
+	tests := []struct {
+		specVersion string
+		metadata bool
+		properties bool
+	}{
+		{"1.1", false, false},
+		{"1.2", true, false},
+		{"1.3", true, true},
+		{"1.4", true, true},
+		{"1.5", true, true},
+	}
+	for _, test := range tests {
+		xmlBytes, encodeError := bom.Encode(test.specVersion, FormatXml)
+		if encodeError != nil {
+			t.Errorf("Encode(%q, xml) returned %v", test.specVersion, encodeError)
+			continue
+		}
+		content := string(xmlBytes)
+		if !strings.Contains(content, `xmlns="http://cyclonedx.org/schema/bom/` + test.specVersion + `"`) {
+			t.Errorf("Encode(%q, xml) has no %v namespace", test.specVersion, test.specVersion)
+		}
+		if !strings.Contains(content, `<component bom-ref="pkg:npm/lodash@4.17.21" type="library">`) {
+			t.Errorf("Encode(%q, xml) has no lodash component with a bom-ref", test.specVersion)
+		}
+		checks := []struct {
+			name string
+			element string
+			want bool
+		}{
+			{"tool", "<name>" + ToolName + "</name>", test.metadata},
+			{"root component", `<component bom-ref="owner/repository" type="application">`, test.metadata},
+			{"dependency graph", `<dependency ref="owner/repository">`, test.metadata},
+			{"manifest property", `<property name="` + ManifestProperty + `">web/package.json</property>`, test.properties},
+		}
+		for _, check := range checks {
+			if strings.Contains(content, check.element) != check.want {
+				t.Errorf("Encode(%q, xml) has %v = %v, want %v", test.specVersion, check.name, !check.want, check.want)
+			}
+		}
+	}
+}
+
+func TestEncodeJson(t *testing.T) {
+	bom := newTestSbom(t)
+	jsonBytes, encodeError := bom.Encode("1.5", FormatJson)
+	if encodeError != nil {
+		t.Fatal(encodeError)
+	}
+	var decoded struct {
+		BomFormat string
+		SpecVersion string
+		SerialNumber string
+		Metadata struct {
+			Tools []struct {
+				Name string
+			}
+			Component struct {
+				BomRef string `json:"bom-ref"`
+				Name string
+			}
+		}
+		Components []struct {
+			BomRef string `json:"bom-ref"`
+			Purl string
+			Properties []struct {
+				Name string
+				Value string
+			}
+		}
+		Dependencies []struct {
+			Ref string
+			DependsOn []string
+		}
+	}
+	if unmarshalError := json.Unmarshal(jsonBytes, &decoded); unmarshalError != nil {
+		t.Fatal(unmarshalError)
+	}
+	if decoded.BomFormat != "CycloneDX" || decoded.SpecVersion != "1.5" || !strings.HasPrefix(decoded.SerialNumber, "urn:uuid:") {
+		t.Errorf("Encode(1.5, json) header = %v %v %v", decoded.BomFormat, decoded.SpecVersion, decoded.SerialNumber)
+	}
+	if len(decoded.Metadata.Tools) != 1 || decoded.Metadata.Tools[0].Name != ToolName {
+		t.Errorf("Encode(1.5, json) tools = %v, want %v", decoded.Metadata.Tools, ToolName)
+	}
+	if decoded.Metadata.Component.BomRef != "owner/repository" || decoded.Metadata.Component.Name != "repository" {
+		t.Errorf("Encode(1.5, json) root component = %v", decoded.Metadata.Component)
+	}
+	if len(decoded.Components) != 2 || decoded.Components[0].BomRef != decoded.Components[0].Purl {
+		t.Fatalf("Encode(1.5, json) components = %v", decoded.Components)
+	}
+	var manifests []string
+	for _, property := range decoded.Components[0].Properties {
+		if property.Name == ManifestProperty {
+			manifests = append(manifests, property.Value)
+		}
+	}
+	if strings.Join(manifests, ",") != "package.json,web/package.json" {
+		t.Errorf("Encode(1.5, json) lodash manifests = %v, want package.json,web/package.json", manifests)
+	}
+	if len(decoded.Dependencies) != 1 || decoded.Dependencies[0].Ref != "owner/repository" ||
+		strings.Join(decoded.Dependencies[0].DependsOn, ",") != "pkg:npm/lodash@4.17.21,pkg:npm/react@18.2.0" {
+		t.Errorf("Encode(1.5, json) dependencies = %v", decoded.Dependencies)
+	}
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	bom := newTestSbom(t)
+	tests := []struct {
+		specVersion string
+		format string
+	}{
+		{"1.1", FormatJson},
+		{"1.6", FormatXml},
+		{"1.5", "yaml"},
+	}
+	for _, test := range tests {
+		if _, encodeError := bom.Encode(test.specVersion, test.format); encodeError == nil {
+			t.Errorf("Encode(%q, %q) returned no error", test.specVersion, test.format)
+		}
+	}
+}
+
+func TestEncodeOptionalVersion(t *testing.T) {
+	bom := newEmptySbom()
+	bom.Components = append(bom.Components, Component{BomRef: "pkg:github/actions/checkout", Type: "library", Name: "checkout", Purl: "pkg:github/actions/checkout"})
+	for specVersion, want := range map[string]bool{"1.3": true, "1.4": false} {
+		xmlBytes, encodeError := bom.Encode(specVersion, FormatXml)
+		if encodeError != nil {
+			t.Fatal(encodeError)
+		}
+		if strings.Contains(string(xmlBytes), "<version></version>") != want {
+			t.Errorf("Encode(%q, xml) has an empty version = %v, want %v", specVersion, !want, want)
+		}
+	}
+}
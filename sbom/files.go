@@ -0,0 +1,119 @@
+package sbom
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Nested archives are read into memory, larger entries are fingerprinted without looking inside them.
+const maxNestedArchiveSize = 256 * 1024 * 1024
+
+var archiveExtensions = []string{".jar", ".war", ".ear", ".zip", ".nupkg", ".aar", ".hpi", ".sar", ".rar"}
+
+// NewFileSbom fingerprints every file under path and the archives nested inside them with SHA-1, which IQ Server
+// matches against known components the same way the Nexus IQ CLI does.
+func NewFileSbom(path string) (*Sbom, error) {
+	sbom := newEmptySbom()
+	seen := make(map[string]bool)
+
+	walkError := filepath.Walk(path, func(filePath string, info os.FileInfo, walkError error) error {
+		if walkError != nil || info.IsDir() {
+			return walkError
+		}
+		file, openError := os.Open(filePath)
+		if openError != nil {
+			return openError
+		}
+		defer file.Close()
+		name, _ := filepath.Rel(path, filePath)
+		hash := sha1.New()
+		_, copyError := io.Copy(hash, file)
+		if copyError != nil {
+			return copyError
+		}
+		sbom.addFile(seen, filepath.ToSlash(name), hex.EncodeToString(hash.Sum(nil)))
+		if !isArchive(name) {
+			return nil
+		}
+		archive, zipError := zip.OpenReader(filePath)
+		if zipError != nil {
+			// Not every file with an archive extension is a zip, it is still matched by its own fingerprint
+			return nil
+		}
+		defer archive.Close()
+		return sbom.addNested(seen, filepath.ToSlash(name), &archive.Reader)
+	})
+	if walkError != nil {
+		return nil, walkError
+	}
+	return sbom, nil
+}
+
+func (sbom *Sbom) addNested(seen map[string]bool, parent string, archive *zip.Reader) error {
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !isArchive(entry.Name) {
+			continue
+		}
+		entryReader, openError := entry.Open()
+		if openError != nil {
+			return openError
+		}
+		hash := sha1.New()
+		var entryBytes []byte
+		var readError error
+		if entry.UncompressedSize64 <= maxNestedArchiveSize {
+			entryBytes, readError = ioutil.ReadAll(entryReader)
+			hash.Write(entryBytes)
+		} else {
+			_, readError = io.Copy(hash, entryReader)
+		}
+		_ = entryReader.Close()
+		if readError != nil {
+			return readError
+		}
+		name := parent + "!/" + entry.Name
+		sbom.addFile(seen, name, hex.EncodeToString(hash.Sum(nil)))
+		if entryBytes == nil {
+			continue
+		}
+		nested, zipError := zip.NewReader(bytes.NewReader(entryBytes), int64(len(entryBytes)))
+		if zipError != nil {
+			continue
+		}
+		nestedError := sbom.addNested(seen, name, nested)
+		if nestedError != nil {
+			return nestedError
+		}
+	}
+	return nil
+}
+
+func (sbom *Sbom) addFile(seen map[string]bool, name string, sha1 string) {
+	if seen[sha1] {
+		return
+	}
+	seen[sha1] = true
+	component := Component{BomRef: "sha1:" + sha1, Type: "file", Name: name}
+	if isArchive(name) {
+		component.Type = "library"
+	}
+	component.Hashes = []Hash{{Alg: "SHA-1", Value: sha1}}
+	sbom.Components = append(sbom.Components, component)
+}
+
+func isArchive(name string) bool {
+	extension := strings.ToLower(filepath.Ext(name))
+	for _, archiveExtension := range archiveExtensions {
+		if extension == archiveExtension {
+			return true
+		}
+	}
+	return false
+}
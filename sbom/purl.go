@@ -0,0 +1,100 @@
+package sbom
+
+import (
+	"errors"
+	"github.com/package-url/packageurl-go"
+	"regexp"
+	"strings"
+)
+
+var pypiSeparators = regexp.MustCompile(`[-_.]+`)
+
+var packageManagers = []string{"maven", "npm", "nuget", "pip", "rubygems", "go", "composer", "cargo", "actions"}
+
+// Package managers named differently by the GitHub dependency graph, which reports Cargo as RUST
+var packageManagerAliases = map[string]string{"rust": "cargo"}
+
+// normalizePackageManager lower cases the package manager and replaces an alias with the name used here.
+func normalizePackageManager(packageManager string) string {
+	manager := strings.ToLower(packageManager)
+	if alias, isAlias := packageManagerAliases[manager]; isAlias {
+		return alias
+	}
+	return manager
+}
+
+func isSupported(packageManager string) bool {
+	for _, supported := range packageManagers {
+		if normalizePackageManager(packageManager) == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// newComponent maps a dependency graph package of a supported ecosystem to a component identified by its purl.
+func newComponent(packageManager string, packageName string, version string) (*Component, error) {
+	component := new(Component)
+	component.Type = "library"
+	component.Version = version
+	var purlType, namespace, name, subpath string
+	var qualifiers packageurl.Qualifiers
+	switch normalizePackageManager(packageManager) {
+	case "maven":
+		ga := strings.Split(packageName, ":")
+		if len(ga) < 2 {
+			return nil, errors.New("maven dependency is not groupId:artifactId - " + packageName)
+		}
+		purlType, namespace, name = packageurl.TypeMaven, ga[0], ga[1]
+		qualifiers = packageurl.QualifiersFromMap(map[string] string{
+			"type": "jar",
+		})
+	case "npm":
+		purlType = packageurl.TypeNPM
+		namespace, name = splitLast(packageName, "/")
+	case "nuget":
+		purlType, name = packageurl.TypeNuget, packageName
+	case "pip":
+		// PyPI names are case insensitive and treat runs of -, _ and . alike
+		purlType, name = packageurl.TypePyPi, pypiSeparators.ReplaceAllString(strings.ToLower(packageName), "-")
+	case "rubygems":
+		purlType, name = packageurl.TypeGem, packageName
+	case "go":
+		// The module path up to the last element is the namespace, e.g. github.com/pkg for github.com/pkg/errors
+		purlType = packageurl.TypeGolang
+		namespace, name = splitLast(packageName, "/")
+	case "composer":
+		vendorName := strings.SplitN(strings.ToLower(packageName), "/", 2)
+		if len(vendorName) < 2 {
+			return nil, errors.New("composer dependency is not vendor/name - " + packageName)
+		}
+		purlType, namespace, name = packageurl.TypeComposer, vendorName[0], vendorName[1]
+	case "cargo":
+		purlType, name = "cargo", packageName
+	case "actions":
+		// Actions are owner/repository, optionally followed by the path of an action within the repository
+		parts := strings.SplitN(packageName, "/", 3)
+		if len(parts) < 2 {
+			return nil, errors.New("actions dependency is not owner/repository - " + packageName)
+		}
+		purlType, namespace, name = packageurl.TypeGithub, strings.ToLower(parts[0]), strings.ToLower(parts[1])
+		if len(parts) == 3 {
+			subpath = parts[2]
+		}
+	default:
+		return nil, errors.New("unsupported package manager - " + packageManager)
+	}
+	component.Group = namespace
+	component.Name = name
+	component.Purl = packageurl.NewPackageURL(purlType, namespace, name, version, qualifiers, subpath).String()
+	return component, nil
+}
+
+// splitLast splits at the last separator, the first part is empty without one.
+func splitLast(value string, separator string) (string, string) {
+	index := strings.LastIndex(value, separator)
+	if index < 0 {
+		return "", value
+	}
+	return value[:index], value[index + len(separator):]
+}
@@ -0,0 +1,76 @@
+package sbom
+
+import (
+	"iq-scm-audit/scm"
+	"testing"
+)
+
+func TestNewComponent(t *testing.T) {
+	tests := []struct {
+		packageManager string
+		packageName string
+		version string
+		purl string
+	}{
+		{"MAVEN", "org.apache.commons:commons-lang3", "3.12.0", "pkg:maven/org.apache.commons/commons-lang3@3.12.0?type=jar"},
+		{"NPM", "lodash", "4.17.21", "pkg:npm/lodash@4.17.21"},
+		{"NPM", "@angular/core", "16.0.0", "pkg:npm/%40angular/core@16.0.0"},
+		{"NUGET", "Newtonsoft.Json", "13.0.1", "pkg:nuget/Newtonsoft.Json@13.0.1"},
+		{"PIP", "Django_REST.framework", "3.14.0", "pkg:pypi/django-rest-framework@3.14.0"},
+		{"RUBYGEMS", "rails", "7.1.3", "pkg:gem/rails@7.1.3"},
+		{"GO", "github.com/pkg/errors", "v0.9.1", "pkg:golang/github.com/pkg/errors@v0.9.1"},
+		{"COMPOSER", "Symfony/Console", "6.3.0", "pkg:composer/symfony/console@6.3.0"},
+		{"CARGO", "serde", "1.0.188", "pkg:cargo/serde@1.0.188"},
+		{"RUST", "serde", "1.0.188", "pkg:cargo/serde@1.0.188"},
+		{"ACTIONS", "actions/checkout", "v4", "pkg:github/actions/checkout@v4"},
+		{"ACTIONS", "github/codeql-action/init", "v2", "pkg:github/github/codeql-action@v2#init"},
+	}
+	for _, test := range tests {
+		component, componentError := newComponent(test.packageManager, test.packageName, test.version)
+		if componentError != nil {
+			t.Errorf("newComponent(%q, %q, %q) returned %v", test.packageManager, test.packageName, test.version, componentError)
+			continue
+		}
+		if component.Purl != test.purl {
+			t.Errorf("newComponent(%q, %q, %q).Purl = %q, want %q", test.packageManager, test.packageName, test.version, component.Purl, test.purl)
+		}
+	}
+}
+
+func TestNewComponentInvalidName(t *testing.T) {
+	for _, dependency := range []scm.Dependency{{PackageManager: "MAVEN", PackageName: "commons-lang3"},
+		{PackageManager: "COMPOSER", PackageName: "console"}, {PackageManager: "ACTIONS", PackageName: "checkout"},
+		{PackageManager: "PUB", PackageName: "http"}} {
+		if _, componentError := newComponent(dependency.PackageManager, dependency.PackageName, "1.0"); componentError == nil {
+			t.Errorf("newComponent(%q, %q) returned no error", dependency.PackageManager, dependency.PackageName)
+		}
+	}
+}
+
+func TestNewSbomSkipsUnmappedDependencies(t *testing.T) {
+	repository := scm.Repository{Name: "repository", NameWithOwner: "owner/repository", Manifests: []scm.Manifest{
+		{Filename: "pom.xml", Dependencies: []scm.Dependency{
+			{PackageManager: "MAVEN", PackageName: "org.apache.commons:commons-lang3", Requirements: "= 3.12.0"},
+			{PackageManager: "MAVEN", PackageName: "commons-lang3", Requirements: "= 3.12.0"},
+		}},
+		{Filename: "Cargo.lock", Dependencies: []scm.Dependency{
+			{PackageManager: "RUST", PackageName: "serde", Requirements: "= 1.0.188"},
+		}},
+		{Filename: "pubspec.yaml", Dependencies: []scm.Dependency{
+			{PackageManager: "PUB", PackageName: "http", Requirements: "= 1.1.0"},
+		}},
+	}}
+	sbom, sbomError := NewSbom(repository, nil)
+	if sbomError != nil {
+		t.Fatal(sbomError)
+	}
+	if len(sbom.Components) != 2 {
+		t.Errorf("NewSbom listed %v components, want 2", len(sbom.Components))
+	}
+	if sbom.Skipped["maven"] != 1 || sbom.Skipped["pub"] != 1 || sbom.SkippedCount() != 2 {
+		t.Errorf("NewSbom skipped %v, want maven 1 and pub 1", sbom.Skipped)
+	}
+	if len(sbom.Invalid) != 1 {
+		t.Errorf("NewSbom listed %v invalid dependencies, want 1", sbom.Invalid)
+	}
+}
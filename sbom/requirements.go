@@ -0,0 +1,458 @@
+package sbom
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var comparatorPattern = regexp.MustCompile(`(~>|~=|===|==|!=|>=|<=|\^|~|>|<|=)?\s*([^\s,<>=~^!][^\s,<>=]*)`)
+var hyphenRangePattern = regexp.MustCompile(`^\s*(\S+)\s+-\s+(\S+)\s*$`)
+var intervalPattern = regexp.MustCompile(`[\[(][^\])]*[\])]`)
+var versionTokenPattern = regexp.MustCompile(`[0-9]+|[a-z]+`)
+
+// Ecosystems whose versions follow semver, where a - starts a pre-release. Maven has its own qualifier ordering and the
+// rest, such as PyPI and RubyGems, mark pre-releases with letters, e.g. 1.0rc1 or 1.0.0.pre.
+var semverManagers = []string{"npm", "go", "composer", "cargo", "nuget"}
+
+// Maven qualifiers from oldest to newest, a release has no qualifier and unknown qualifiers, e.g. jre, come after sp.
+var mavenQualifiers = []string{"alpha", "beta", "milestone", "rc", "snapshot", "", "sp"}
+var mavenQualifierAliases = map[string]string{"a": "alpha", "b": "beta", "m": "milestone", "cr": "rc", "ga": "", "final": "", "release": ""}
+
+// Requirement is a parsed dependency graph version requirement. Version is the pinned version, it is empty when the
+// requirement only gives a range, e.g. "^1.2.0", ">= 2.0, < 3.0", "~> 4.1", "1.2.*" or "[1.0,2.0)".
+type Requirement struct {
+	Version string
+	Range string
+	manager string
+	// Alternatives of constraints that must all hold, nil when the range could not be parsed
+	alternatives [][]constraint
+}
+
+type constraint struct {
+	operator string
+	version string
+}
+
+// ParseRequirement reads a requirement in the notation of the package manager. An operator of =, == or === pins the
+// version, as does a bare version except for Cargo, where it is a caret range, and partial npm versions, which are
+// x-ranges.
+func ParseRequirement(packageManager string, requirements string) Requirement {
+	manager := strings.ToLower(packageManager)
+	requirement := Requirement{Range: strings.TrimSpace(requirements), manager: manager}
+	switch {
+	case len(requirement.Range) == 0 || requirement.Range == "*" || requirement.Range == "latest":
+		requirement.alternatives = [][]constraint{{}}
+	case strings.HasPrefix(requirement.Range, "[") || strings.HasPrefix(requirement.Range, "("):
+		for _, interval := range intervalPattern.FindAllString(requirement.Range, -1) {
+			constraints, parsed := parseInterval(interval)
+			if !parsed {
+				requirement.alternatives = nil
+				break
+			}
+			requirement.alternatives = append(requirement.alternatives, constraints)
+		}
+	default:
+		for _, alternative := range strings.Split(requirement.Range, "||") {
+			constraints, parsed := parseComparators(manager, alternative)
+			if !parsed {
+				requirement.alternatives = nil
+				break
+			}
+			requirement.alternatives = append(requirement.alternatives, constraints)
+		}
+	}
+	if len(requirement.alternatives) == 1 && len(requirement.alternatives[0]) == 1 && requirement.alternatives[0][0].operator == "=" {
+		requirement.Version = requirement.alternatives[0][0].version
+	}
+	return requirement
+}
+
+// Allows reports whether the version is within the requirement, a requirement that could not be parsed allows none.
+func (requirement Requirement) Allows(version string) bool {
+	for _, constraints := range requirement.alternatives {
+		allowed := true
+		for _, constraint := range constraints {
+			if !constraint.allows(requirement.manager, version) {
+				allowed = false
+				break
+			}
+		}
+		if allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (requirement Requirement) namesPreRelease() bool {
+	for _, constraints := range requirement.alternatives {
+		for _, constraint := range constraints {
+			if IsPreRelease(requirement.manager, constraint.version) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (constraint constraint) allows(manager string, version string) bool {
+	comparison := compareVersions(manager, version, constraint.version)
+	switch constraint.operator {
+	case "=":
+		return comparison == 0
+	case "!=":
+		return comparison != 0
+	case ">":
+		return comparison > 0
+	case ">=":
+		return comparison >= 0
+	case "<":
+		return comparison < 0
+	case "<=":
+		return comparison <= 0
+	}
+	return false
+}
+
+func parseComparators(manager string, alternative string) ([]constraint, bool) {
+	if hyphenRange := hyphenRangePattern.FindStringSubmatch(alternative); hyphenRange != nil {
+		return []constraint{{">=", hyphenRange[1]}, {"<=", hyphenRange[2]}}, true
+	}
+	constraints := []constraint{}
+	for _, comparator := range comparatorPattern.FindAllStringSubmatch(strings.TrimSpace(alternative), -1) {
+		operator, version := comparator[1], comparator[2]
+		var expanded []constraint
+		var parsed bool
+		switch {
+		case isWildcard(version):
+			expanded, parsed = wildcardRange(version)
+		case operator == "^":
+			expanded, parsed = caretRange(version)
+		case operator == "~" && manager == "composer", operator == "~>", operator == "~=":
+			expanded, parsed = pessimisticRange(version)
+		case operator == "~":
+			expanded, parsed = tildeRange(version)
+		case operator == "==" || operator == "===":
+			expanded, parsed = []constraint{{"=", version}}, true
+		case len(operator) == 0 && manager == "cargo":
+			expanded, parsed = caretRange(version)
+		case len(operator) == 0 && manager == "npm" && len(releaseParts(version)) < 3:
+			expanded, parsed = wildcardRange(version + ".x")
+		case len(operator) == 0:
+			expanded, parsed = []constraint{{"=", version}}, true
+		default:
+			expanded, parsed = []constraint{{operator, version}}, true
+		}
+		if !parsed {
+			return nil, false
+		}
+		constraints = append(constraints, expanded...)
+	}
+	return constraints, true
+}
+
+// parseInterval reads Maven and NuGet interval notation, e.g. [1.0,2.0), (,1.0] or [1.2].
+func parseInterval(interval string) ([]constraint, bool) {
+	bounds := strings.Split(interval[1:len(interval) - 1], ",")
+	lower, upper := strings.TrimSpace(bounds[0]), ""
+	if len(bounds) == 1 {
+		return []constraint{{"=", lower}}, interval[0] == '[' && interval[len(interval) - 1] == ']'
+	}
+	if len(bounds) > 2 {
+		return nil, false
+	}
+	upper = strings.TrimSpace(bounds[1])
+	var constraints []constraint
+	if len(lower) > 0 {
+		operator := ">"
+		if interval[0] == '[' {
+			operator = ">="
+		}
+		constraints = append(constraints, constraint{operator, lower})
+	}
+	if len(upper) > 0 {
+		operator := "<"
+		if interval[len(interval) - 1] == ']' {
+			operator = "<="
+		}
+		constraints = append(constraints, constraint{operator, upper})
+	}
+	return constraints, true
+}
+
+func isWildcard(version string) bool {
+	for _, part := range releaseParts(version) {
+		if part == "*" || part == "x" || part == "X" {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardRange allows every version starting with the parts before the wildcard, e.g. 1.2.* is >= 1.2 and < 1.3.
+func wildcardRange(version string) ([]constraint, bool) {
+	var prefix []string
+	for _, part := range releaseParts(version) {
+		if part == "*" || part == "x" || part == "X" {
+			break
+		}
+		prefix = append(prefix, part)
+	}
+	if len(prefix) == 0 {
+		return []constraint{}, true
+	}
+	return boundedRange(strings.Join(prefix, "."), prefix, len(prefix) - 1)
+}
+
+// caretRange allows changes that do not modify the first non-zero part, e.g. ^1.2.3 is < 2.0.0 and ^0.2.3 is < 0.3.0.
+func caretRange(version string) ([]constraint, bool) {
+	parts := releaseParts(version)
+	index := len(parts) - 1
+	for partIndex, part := range parts {
+		if part != "0" {
+			index = partIndex
+			break
+		}
+	}
+	return boundedRange(version, parts, index)
+}
+
+// tildeRange allows patch changes, or minor changes when only a major version is given, e.g. ~1.2.3 is < 1.3.0.
+func tildeRange(version string) ([]constraint, bool) {
+	parts := releaseParts(version)
+	index := 0
+	if len(parts) >= 2 {
+		index = 1
+	}
+	return boundedRange(version, parts, index)
+}
+
+// pessimisticRange allows changes to the last part given, e.g. ~> 4.1 is < 5.0 and ~= 1.4.5 is < 1.5.
+func pessimisticRange(version string) ([]constraint, bool) {
+	parts := releaseParts(version)
+	index := 0
+	if len(parts) >= 2 {
+		index = len(parts) - 2
+	}
+	return boundedRange(version, parts, index)
+}
+
+// boundedRange allows the version up to, but excluding, the next value of the part at index.
+func boundedRange(version string, parts []string, index int) ([]constraint, bool) {
+	next, numberError := strconv.Atoi(parts[index])
+	if numberError != nil {
+		return nil, false
+	}
+	upper := append(append([]string{}, parts[:index]...), strconv.Itoa(next + 1))
+	return []constraint{{">=", version}, {"<", strings.Join(upper, ".")}}, true
+}
+
+// releaseParts splits the version without a v prefix, pre-release or build metadata into its dot separated parts.
+func releaseParts(version string) []string {
+	release, _ := splitPreRelease(version)
+	return strings.Split(release, ".")
+}
+
+func splitPreRelease(version string) (string, string) {
+	version = strings.TrimPrefix(strings.TrimPrefix(version, "v"), "V")
+	version = strings.SplitN(version, "+", 2)[0]
+	releasePreRelease := strings.SplitN(version, "-", 2)
+	if len(releasePreRelease) == 1 {
+		return releasePreRelease[0], ""
+	}
+	return releasePreRelease[0], releasePreRelease[1]
+}
+
+// compareVersions compares versions in the scheme of the package manager. Semver compares numeric parts as numbers and
+// other parts as text, missing parts count as 0 and a pre-release is before its release.
+func compareVersions(manager string, a string, b string) int {
+	if manager == "maven" {
+		return compareMavenVersions(a, b)
+	}
+	if !isSemver(manager) {
+		return compareTokens(versionTokens(a), versionTokens(b))
+	}
+	aRelease, aPreRelease := splitPreRelease(a)
+	bRelease, bPreRelease := splitPreRelease(b)
+	comparison := compareParts(strings.Split(aRelease, "."), strings.Split(bRelease, "."), "0")
+	if comparison != 0 {
+		return comparison
+	}
+	switch {
+	case aPreRelease == bPreRelease:
+		return 0
+	case len(aPreRelease) == 0:
+		return 1
+	case len(bPreRelease) == 0:
+		return -1
+	}
+	return compareParts(strings.Split(aPreRelease, "."), strings.Split(bPreRelease, "."), "")
+}
+
+func compareParts(aParts []string, bParts []string, missing string) int {
+	for index := 0; index < len(aParts) || index < len(bParts); index++ {
+		aPart, bPart := missing, missing
+		if index < len(aParts) {
+			aPart = aParts[index]
+		}
+		if index < len(bParts) {
+			bPart = bParts[index]
+		}
+		aNumber, aError := strconv.Atoi(aPart)
+		bNumber, bError := strconv.Atoi(bPart)
+		switch {
+		case aError == nil && bError == nil && aNumber != bNumber:
+			if aNumber < bNumber {
+				return -1
+			}
+			return 1
+		case (aError != nil || bError != nil) && aPart != bPart:
+			if aPart < bPart {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// compareTokens compares numbers as numbers and text as text, text comes before a number or a missing token, so
+// 1.0rc1 and 1.0.0.pre are before 1.0 and 1.0.0.
+func compareTokens(aTokens []string, bTokens []string) int {
+	for index := 0; index < len(aTokens) || index < len(bTokens); index++ {
+		aToken, bToken := "0", "0"
+		if index < len(aTokens) {
+			aToken = aTokens[index]
+		}
+		if index < len(bTokens) {
+			bToken = bTokens[index]
+		}
+		aNumber, aError := strconv.Atoi(aToken)
+		bNumber, bError := strconv.Atoi(bToken)
+		switch {
+		case aError == nil && bError == nil && aNumber != bNumber:
+			if aNumber < bNumber {
+				return -1
+			}
+			return 1
+		case aError != nil && bError == nil:
+			return -1
+		case aError == nil && bError != nil:
+			return 1
+		case aError != nil && bError != nil && aToken != bToken:
+			if aToken < bToken {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// compareMavenVersions follows the Maven qualifier ordering, alpha < beta < milestone < rc < snapshot < release < sp <
+// other qualifiers, numbers come after qualifiers and missing numbers count as 0, so 31.1-jre is after 31.1.
+func compareMavenVersions(a string, b string) int {
+	aTokens, bTokens := versionTokens(a), versionTokens(b)
+	for index := 0; index < len(aTokens) || index < len(bTokens); index++ {
+		var aToken, bToken string
+		if index < len(aTokens) {
+			aToken = aTokens[index]
+		}
+		if index < len(bTokens) {
+			bToken = bTokens[index]
+		}
+		comparison := compareMavenTokens(aToken, bToken)
+		if comparison != 0 {
+			return comparison
+		}
+	}
+	return 0
+}
+
+// compareMavenTokens compares a number or qualifier, an empty token is missing.
+func compareMavenTokens(a string, b string) int {
+	aNumber, aError := strconv.Atoi(a)
+	bNumber, bError := strconv.Atoi(b)
+	switch {
+	case aError == nil && len(b) == 0:
+		bNumber, bError = 0, nil
+	case bError == nil && len(a) == 0:
+		aNumber, aError = 0, nil
+	}
+	switch {
+	case aError == nil && bError == nil:
+		if aNumber < bNumber {
+			return -1
+		}
+		if aNumber > bNumber {
+			return 1
+		}
+		return 0
+	case aError == nil:
+		return 1
+	case bError == nil:
+		return -1
+	}
+	aRank, bRank := mavenQualifierRank(a), mavenQualifierRank(b)
+	switch {
+	case aRank != bRank && aRank < bRank:
+		return -1
+	case aRank != bRank:
+		return 1
+	case aRank == len(mavenQualifiers) && a != b:
+		if a < b {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func mavenQualifierRank(qualifier string) int {
+	if alias, isAlias := mavenQualifierAliases[qualifier]; isAlias {
+		qualifier = alias
+	}
+	for rank, known := range mavenQualifiers {
+		if known == qualifier {
+			return rank
+		}
+	}
+	return len(mavenQualifiers)
+}
+
+// versionTokens splits the version without a v prefix or build metadata into its numbers and words, e.g. 1.0rc1 is 1, 0,
+// rc and 1.
+func versionTokens(version string) []string {
+	version = strings.TrimPrefix(strings.ToLower(version), "v")
+	version = strings.SplitN(version, "+", 2)[0]
+	return versionTokenPattern.FindAllString(version, -1)
+}
+
+func isSemver(manager string) bool {
+	for _, semverManager := range semverManagers {
+		if manager == semverManager {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPreRelease reports whether the version is a pre-release in the scheme of the package manager, e.g. 2.0.0-beta.1 for
+// npm, 2.0-rc1 but not 31.1-jre for Maven, and 2.0rc1 or 2.0.0.pre for PyPI and RubyGems.
+func IsPreRelease(packageManager string, version string) bool {
+	manager := strings.ToLower(packageManager)
+	if isSemver(manager) {
+		_, preRelease := splitPreRelease(version)
+		return len(preRelease) > 0
+	}
+	for _, token := range versionTokens(version) {
+		if _, numberError := strconv.Atoi(token); numberError == nil {
+			continue
+		}
+		if manager != "maven" || mavenQualifierRank(token) < mavenQualifierRank("") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package sbom
+
+import (
+	"testing"
+)
+
+func TestParseRequirement(t *testing.T) {
+	tests := []struct {
+		packageManager string
+		requirement string
+		version string
+	}{
+		{"npm", "= 1.2.3", "1.2.3"},
+		{"npm", "1.2.3", "1.2.3"},
+		{"npm", "1.2", ""},
+		{"npm", "^1.2.0", ""},
+		{"pip", "==2.31.0", "2.31.0"},
+		{"pip", ">= 2.0, < 3.0", ""},
+		{"rubygems", "~> 4.1", ""},
+		{"maven", "[1.2.3]", "1.2.3"},
+		{"maven", "[1.0,2.0)", ""},
+		{"cargo", "1.2.3", ""},
+		{"composer", "1.2.*", ""},
+		{"nuget", "", ""},
+	}
+	for _, test := range tests {
+		requirement := ParseRequirement(test.packageManager, test.requirement)
+		if requirement.Version != test.version {
+			t.Errorf("ParseRequirement(%q, %q).Version = %q, want %q", test.packageManager, test.requirement, requirement.Version, test.version)
+		}
+		if requirement.Range != test.requirement {
+			t.Errorf("ParseRequirement(%q, %q).Range = %q, want %q", test.packageManager, test.requirement, requirement.Range, test.requirement)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		packageManager string
+		requirement string
+		version string
+		allows bool
+	}{
+		{"npm", "^1.2.0", "1.9.9", true},
+		{"npm", "^1.2.0", "2.0.0", false},
+		{"npm", "^0.2.3", "0.3.0", false},
+		{"npm", "~1.2.3", "1.2.9", true},
+		{"npm", "~1.2.3", "1.3.0", false},
+		{"npm", "1.2", "1.2.7", true},
+		{"npm", "1.2.3 - 2.0.0", "2.0.0", true},
+		{"npm", "<1.0.0 || >=2.0.0", "1.5.0", false},
+		{"npm", "<1.0.0 || >=2.0.0", "2.1.0", true},
+		{"npm", ">=1.0.0", "1.0.0-beta.1", false},
+		{"npm", "*", "3.0.0", true},
+		{"pip", ">= 2.0, < 3.0", "2.31.0", true},
+		{"pip", ">=1.0<2.0", "2.0", false},
+		{"pip", ">=1.0<2.0", "1.5", true},
+		{"pip", "~=1.4.5", "1.4.9", true},
+		{"pip", "~=1.4.5", "1.5.0", false},
+		{"pip", ">=2.0", "2.0rc1", false},
+		{"pip", "!=2.1", "2.1", false},
+		{"rubygems", "~> 4.1", "4.9", true},
+		{"rubygems", "~> 4.1", "5.0", false},
+		{"rubygems", ">= 7.1", "7.1.0.beta1", false},
+		{"composer", "~1.2", "1.9", true},
+		{"composer", "1.2.*", "1.3.0", false},
+		{"cargo", "1.2.3", "1.9.0", true},
+		{"cargo", "1.2.3", "2.0.0", false},
+		{"maven", "[1.0,2.0)", "1.5", true},
+		{"maven", "[1.0,2.0)", "2.0", false},
+		{"maven", "(,1.0]", "1.0", true},
+		{"maven", "[31.0,32.0)", "31.1-jre", true},
+		{"maven", "[31.0,32.0)", "32.0-jre", false},
+		{"maven", "[1.0,)", "1.0-rc1", false},
+		{"maven", "(,1.0)", "1.0-SNAPSHOT", true},
+		{"maven", "(1.0,)", "1.0-sp1", true},
+		{"maven", "[1.0]", "1.0", true},
+	}
+	for _, test := range tests {
+		allows := ParseRequirement(test.packageManager, test.requirement).Allows(test.version)
+		if allows != test.allows {
+			t.Errorf("ParseRequirement(%q, %q).Allows(%q) = %v, want %v", test.packageManager, test.requirement, test.version, allows, test.allows)
+		}
+	}
+}
+
+func TestIsPreRelease(t *testing.T) {
+	tests := []struct {
+		packageManager string
+		version string
+		preRelease bool
+	}{
+		{"npm", "2.0.0-beta.1", true},
+		{"npm", "2.0.0", false},
+		{"maven", "31.1-jre", false},
+		{"maven", "2.0-rc1", true},
+		{"maven", "2.0-SNAPSHOT", true},
+		{"maven", "2.0.Final", false},
+		{"pip", "2.0rc1", true},
+		{"pip", "2.0", false},
+		{"rubygems", "7.1.0.beta1", true},
+	}
+	for _, test := range tests {
+		preRelease := IsPreRelease(test.packageManager, test.version)
+		if preRelease != test.preRelease {
+			t.Errorf("IsPreRelease(%q, %q) = %v, want %v", test.packageManager, test.version, preRelease, test.preRelease)
+		}
+	}
+}
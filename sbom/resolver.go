@@ -0,0 +1,66 @@
+package sbom
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+)
+
+// Resolver picks the version of a dependency whose requirement only gives a range, an empty version leaves it
+// unresolved.
+type Resolver interface {
+	Resolve(packageManager string, packageName string, requirement Requirement) (string, error)
+}
+
+// Registry lists the published versions of a package.
+type Registry interface {
+	Versions(packageManager string, packageName string) ([]string, error)
+}
+
+// RegistryResolver resolves a range to the highest version in the registry that it allows, pre-releases are only
+// picked when the range names one.
+type RegistryResolver struct {
+	Registry Registry
+}
+
+// FileRegistry holds the versions of each package by package manager, read from a JSON file such as an export of an
+// internal mirror or a fixture.
+type FileRegistry map[string]map[string][]string
+
+func (resolver *RegistryResolver) Resolve(packageManager string, packageName string, requirement Requirement) (string, error) {
+	versions, versionsError := resolver.Registry.Versions(packageManager, packageName)
+	if versionsError != nil {
+		return "", versionsError
+	}
+	allowPreRelease := requirement.namesPreRelease()
+	var resolved string
+	for _, version := range versions {
+		if (allowPreRelease || !IsPreRelease(packageManager, version)) && requirement.Allows(version) &&
+			(len(resolved) == 0 || compareVersions(requirement.manager, version, resolved) > 0) {
+			resolved = version
+		}
+	}
+	return resolved, nil
+}
+
+// LoadFileRegistry reads {"npm": {"lodash": ["4.17.20", "4.17.21"]}}, package managers are matched ignoring case.
+func LoadFileRegistry(path string) (FileRegistry, error) {
+	registryBytes, readError := ioutil.ReadFile(path)
+	if readError != nil {
+		return nil, readError
+	}
+	var fileRegistry FileRegistry
+	unmarshalError := json.Unmarshal(registryBytes, &fileRegistry)
+	if unmarshalError != nil {
+		return nil, unmarshalError
+	}
+	registry := make(FileRegistry)
+	for packageManager, packages := range fileRegistry {
+		registry[strings.ToLower(packageManager)] = packages
+	}
+	return registry, nil
+}
+
+func (registry FileRegistry) Versions(packageManager string, packageName string) ([]string, error) {
+	return registry[strings.ToLower(packageManager)][packageName], nil
+}
@@ -0,0 +1,41 @@
+package sbom
+
+import (
+	"testing"
+)
+
+func TestRegistryResolver(t *testing.T) {
+	registry, loadError := LoadFileRegistry("testdata/registry.json")
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+	resolver := &RegistryResolver{Registry: registry}
+	tests := []struct {
+		packageManager string
+		packageName string
+		requirement string
+		version string
+	}{
+		{"MAVEN", "com.google.guava:guava", "[31.0,32.0)", "31.1-jre"},
+		{"maven", "com.google.guava:guava", "[31.0,)", "32.1.2-jre"},
+		{"maven", "com.google.guava:guava", "[32.0.0-rc1,32.1)", "32.0.0-rc1"},
+		{"npm", "lodash", "^4.17.0", "4.17.21"},
+		{"npm", "lodash", ">=4.0.0", "4.17.21"},
+		{"npm", "lodash", ">=5.0.0-beta.0", "5.0.0-beta.1"},
+		{"npm", "react", "^17.0.0 || ^18.0.0", "18.2.0"},
+		{"pip", "requests", ">=2.0,<3.0", "2.31.0"},
+		{"rubygems", "rails", "~> 7.0", "7.1.3"},
+		{"rubygems", "rails", "~> 7.0.0", "7.0.8"},
+		{"npm", "lodash", "^6.0.0", ""},
+		{"npm", "missing", "^1.0.0", ""},
+	}
+	for _, test := range tests {
+		version, resolveError := resolver.Resolve(test.packageManager, test.packageName, ParseRequirement(test.packageManager, test.requirement))
+		if resolveError != nil {
+			t.Fatal(resolveError)
+		}
+		if version != test.version {
+			t.Errorf("Resolve(%q, %q, %q) = %q, want %q", test.packageManager, test.packageName, test.requirement, version, test.version)
+		}
+	}
+}
@@ -1,66 +1,137 @@
 package sbom
 
 import (
-	"encoding/xml"
 	"github.com/google/uuid"
-	"github.com/package-url/packageurl-go"
-	"iq-scm-audit/github"
+	"iq-scm-audit/scm"
 	"strings"
+	"time"
 )
 
+const ToolName = "iq-scm-audit"
+const ManifestProperty = ToolName + ":manifest"
+
+// Sbom is independent of the CycloneDX spec version, it is encoded to a version and format with Encode.
 type Sbom struct {
-	XMLName xml.Name `xml:"bom"`
-	XMLNs string `xml:"xmlns,attr"`
-	Version string `xml:"version,attr"`
-	SerialNumber string `xml:"serialNumber,attr"`
-	Components Components `xml:"components"`
+	SerialNumber string
+	Version int
+	Metadata Metadata
+	Components []Component
+	// Dependencies of unsupported ecosystems or without a purl, by package manager
+	Skipped map[string]int
+	// Dependencies whose requirement is a range that was not resolved to a version, as name and range
+	Unresolved []string
+	// Dependencies whose name has no purl in their ecosystem, e.g. a Maven name without a groupId, as the error
+	Invalid []string
 }
 
-type Components struct {
-	Component[] Component
+type Metadata struct {
+	Timestamp time.Time
+	// The repository the SBOM describes, every component is a dependency of it
+	Component *Component
+	Url string
 }
 
 type Component struct {
-	XMLName xml.Name `xml:"component"`
-	Type string `xml:"type,attr"`
-	Group string `xml:"group"`
-	Name string `xml:"name"`
-	Version string `xml:"version"`
-	Purl string `xml:"purl"`
+	BomRef string
+	Type string
+	Group string
+	Name string
+	Version string
+	Hashes []Hash
+	Purl string
+	Properties []Property
+}
+
+type Hash struct {
+	Alg string
+	Value string
 }
 
-func NewSbom(dependencies[] github.Dependency) *Sbom {
+type Property struct {
+	Name string
+	Value string
+}
+
+func newEmptySbom() *Sbom {
 	sbom := new(Sbom)
-	sbom.XMLNs = "http://cyclonedx.org/schema/bom/1.1"
-	sbom.Version = "1"
 	sbom.SerialNumber = "urn:uuid:" + uuid.New().String()
+	sbom.Version = 1
+	sbom.Metadata.Timestamp = time.Now().UTC()
+	sbom.Components = []Component{}
+	sbom.Skipped = make(map[string]int)
+	return sbom
+}
 
-	for _, dependency := range dependencies {
-		if len(dependency.Requirements) > 2 {
-			component := new(Component)
-			component.Type = "library"
+// NewSbom lists the dependencies of every manifest of the repository, a dependency found in several manifests is listed
+// once with a property for each manifest. Dependencies of ecosystems without a purl type are skipped and counted by
+// package manager in Skipped, as are dependencies of supported ecosystems whose name cannot be mapped, which are also listed
+// in Invalid. Dependencies with only a version range are resolved by the resolver when there is one,
+// those left without a version are listed in Unresolved instead of being scanned as a bogus version.
+func NewSbom(repository scm.Repository, resolver Resolver) (*Sbom, error) {
+	sbom := newEmptySbom()
+	sbom.Metadata.Component = &Component{BomRef: repository.NameWithOwner, Type: "application", Name: repository.Name}
+	if owner := strings.LastIndex(repository.NameWithOwner, "/"); owner > 0 {
+		sbom.Metadata.Component.Group = repository.NameWithOwner[:owner]
+	}
+	sbom.Metadata.Url = repository.Url
+	components := make(map[string]int)
 
-			v := dependency.Requirements[2:]
-			lowerPackageManager := strings.ToLower(dependency.PackageManager)
-			switch lowerPackageManager {
-			case "maven":
-				ga := strings.Split(dependency.PackageName, ":")
-				component.Group = ga[0]
-				component.Name = ga[1]
-				component.Version = v
-				qualifier := packageurl.QualifiersFromMap(map[string] string{
-					"type": "jar",
-				})
-				component.Purl = packageurl.NewPackageURL("maven", ga[0], ga[1], v, qualifier, "").String()
-			case "npm", "nuget":
-				component.Name = dependency.PackageName
-				component.Version = v
-				component.Purl = packageurl.NewPackageURL(lowerPackageManager, "", dependency.PackageName, v, nil, "").String()
+	for _, manifest := range repository.Manifests {
+		for _, dependency := range manifest.Dependencies {
+			packageManager := normalizePackageManager(dependency.PackageManager)
+			if !isSupported(packageManager) {
+				sbom.Skipped[packageManager]++
+				continue
+			}
+			requirement := ParseRequirement(packageManager, dependency.Requirements)
+			v := requirement.Version
+			if len(v) == 0 && resolver != nil {
+				var resolveError error
+				v, resolveError = resolver.Resolve(packageManager, dependency.PackageName, requirement)
+				if resolveError != nil {
+					return nil, resolveError
+				}
+			}
+			if len(v) == 0 {
+				sbom.Unresolved = append(sbom.Unresolved, dependency.PackageName + " " + requirement.Range)
+				continue
+			}
+			component, componentError := newComponent(packageManager, dependency.PackageName, v)
+			if componentError != nil {
+				sbom.Skipped[packageManager]++
+				sbom.Invalid = append(sbom.Invalid, componentError.Error())
+				continue
+			}
+			property := Property{Name: ManifestProperty, Value: manifest.Filename}
+			index, found := components[component.Purl]
+			if !found {
+				component.BomRef = component.Purl
+				component.Properties = []Property{property}
+				components[component.Purl] = len(sbom.Components)
+				sbom.Components = append(sbom.Components, *component)
+			} else if !sbom.Components[index].hasProperty(property) {
+				sbom.Components[index].Properties = append(sbom.Components[index].Properties, property)
 			}
-
-			sbom.Components.Component = append(sbom.Components.Component, *component)
 		}
 	}
 
-	return sbom
-}
\ No newline at end of file
+	return sbom, nil
+}
+
+// SkippedCount is the number of dependencies left out of the SBOM.
+func (sbom *Sbom) SkippedCount() int {
+	count := 0
+	for _, skipped := range sbom.Skipped {
+		count += skipped
+	}
+	return count
+}
+
+func (component *Component) hasProperty(property Property) bool {
+	for _, existing := range component.Properties {
+		if existing == property {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	SpdxTagValue = "tag-value"
+	SpdxJson = "json"
+)
+
+const spdxNamespaceUrl = "https://spdx.org/spdxdocs/"
+const spdxDocumentId = "SPDXRef-DOCUMENT"
+const spdxRepositoryId = "SPDXRef-Repository"
+const spdxNoAssertion = "NOASSERTION"
+
+var spdxIdInvalidCharacters = regexp.MustCompile(`[^A-Za-z0-9.\-]+`)
+
+type spdxDocument struct {
+	SpdxVersion string `json:"spdxVersion"`
+	DataLicense string `json:"dataLicense"`
+	SpdxId string `json:"SPDXID"`
+	Name string `json:"name"`
+	DocumentNamespace string `json:"documentNamespace"`
+	CreationInfo spdxCreationInfo `json:"creationInfo"`
+	Packages []spdxPackage `json:"packages"`
+	Relationships []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created string `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name string `json:"name"`
+	SpdxId string `json:"SPDXID"`
+	VersionInfo string `json:"versionInfo,omitempty"`
+	DownloadLocation string `json:"downloadLocation"`
+	FilesAnalyzed bool `json:"filesAnalyzed"`
+	Checksums []spdxChecksum `json:"checksums,omitempty"`
+	SourceInfo string `json:"sourceInfo,omitempty"`
+	ExternalRefs []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType string `json:"referenceType"`
+	ReferenceLocator string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SpdxElementId string `json:"spdxElementId"`
+	RelationshipType string `json:"relationshipType"`
+	RelatedSpdxElement string `json:"relatedSpdxElement"`
+}
+
+// EncodeSpdx writes the SBOM as an SPDX 2.3 document in tag-value or json. The document describes the repository, which
+// depends on every component, or every component when there is no repository.
+func (sbom *Sbom) EncodeSpdx(format string) ([]byte, error) {
+	document := sbom.toSpdx()
+	switch format {
+	case SpdxTagValue:
+		return document.tagValue(), nil
+	case SpdxJson:
+		return json.MarshalIndent(document, "", "  ")
+	}
+	return nil, errors.New("unsupported SPDX format, use tag-value or json - " + format)
+}
+
+func (sbom *Sbom) toSpdx() spdxDocument {
+	name := "sbom"
+	if sbom.Metadata.Component != nil {
+		name = sbom.Metadata.Component.BomRef
+	}
+	document := spdxDocument{
+		SpdxVersion: "SPDX-2.3",
+		DataLicense: "CC0-1.0",
+		SpdxId: spdxDocumentId,
+		Name: name,
+		DocumentNamespace: spdxNamespaceUrl + spdxIdInvalidCharacters.ReplaceAllString(name, "-") + "-" + strings.TrimPrefix(sbom.SerialNumber, "urn:uuid:"),
+		CreationInfo: spdxCreationInfo{
+			Created: sbom.Metadata.Timestamp.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + ToolName},
+		},
+		Packages: []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+
+	describedId := spdxDocumentId
+	if sbom.Metadata.Component != nil {
+		repository := spdxPackage{
+			Name: name,
+			SpdxId: spdxRepositoryId,
+			DownloadLocation: spdxNoAssertion,
+		}
+		if len(sbom.Metadata.Url) > 0 {
+			repository.DownloadLocation = "git+" + sbom.Metadata.Url
+		}
+		document.Packages = append(document.Packages, repository)
+		document.Relationships = append(document.Relationships, spdxRelationship{spdxDocumentId, "DESCRIBES", spdxRepositoryId})
+		describedId = spdxRepositoryId
+	}
+	for index, component := range sbom.Components {
+		spdxId := fmt.Sprintf("SPDXRef-Package-%v-%v", index + 1, spdxIdInvalidCharacters.ReplaceAllString(component.Name, "-"))
+		name := component.Name
+		if len(component.Group) > 0 && strings.HasPrefix(component.Purl, "pkg:maven/") {
+			name = component.Group + ":" + component.Name
+		} else if len(component.Group) > 0 {
+			name = component.Group + "/" + component.Name
+		}
+		spdxPackage := spdxPackage{
+			Name: name,
+			SpdxId: spdxId,
+			VersionInfo: component.Version,
+			DownloadLocation: spdxNoAssertion,
+		}
+		for _, hash := range component.Hashes {
+			spdxPackage.Checksums = append(spdxPackage.Checksums, spdxChecksum{strings.Replace(hash.Alg, "-", "", -1), hash.Value})
+		}
+		var manifests []string
+		for _, property := range component.Properties {
+			if property.Name == ManifestProperty {
+				manifests = append(manifests, property.Value)
+			}
+		}
+		if len(manifests) > 0 {
+			spdxPackage.SourceInfo = "found in " + strings.Join(manifests, ", ")
+		}
+		if len(component.Purl) > 0 {
+			spdxPackage.ExternalRefs = []spdxExternalRef{{"PACKAGE-MANAGER", "purl", component.Purl}}
+		}
+		document.Packages = append(document.Packages, spdxPackage)
+		relationship := "DEPENDS_ON"
+		if describedId == spdxDocumentId {
+			relationship = "DESCRIBES"
+		}
+		document.Relationships = append(document.Relationships, spdxRelationship{describedId, relationship, spdxId})
+	}
+	return document
+}
+
+func (document spdxDocument) tagValue() []byte {
+	var tagValue bytes.Buffer
+	tag := func(name string, value string) {
+		if len(value) > 0 {
+			tagValue.WriteString(name + ": " + value + "\n")
+		}
+	}
+	tag("SPDXVersion", document.SpdxVersion)
+	tag("DataLicense", document.DataLicense)
+	tag("SPDXID", document.SpdxId)
+	tag("DocumentName", document.Name)
+	tag("DocumentNamespace", document.DocumentNamespace)
+	for _, creator := range document.CreationInfo.Creators {
+		tag("Creator", creator)
+	}
+	tag("Created", document.CreationInfo.Created)
+	for _, spdxPackage := range document.Packages {
+		tagValue.WriteString("\n")
+		tag("PackageName", spdxPackage.Name)
+		tag("SPDXID", spdxPackage.SpdxId)
+		tag("PackageVersion", spdxPackage.VersionInfo)
+		tag("PackageDownloadLocation", spdxPackage.DownloadLocation)
+		tag("FilesAnalyzed", "false")
+		for _, checksum := range spdxPackage.Checksums {
+			tag("PackageChecksum", checksum.Algorithm + ": " + checksum.ChecksumValue)
+		}
+		if len(spdxPackage.SourceInfo) > 0 {
+			tag("PackageSourceInfo", "<text>" + spdxPackage.SourceInfo + "</text>")
+		}
+		for _, externalRef := range spdxPackage.ExternalRefs {
+			tag("ExternalRef", externalRef.ReferenceCategory + " " + externalRef.ReferenceType + " " + externalRef.ReferenceLocator)
+		}
+	}
+	tagValue.WriteString("\n")
+	for _, relationship := range document.Relationships {
+		tag("Relationship", relationship.SpdxElementId + " " + relationship.RelationshipType + " " + relationship.RelatedSpdxElement)
+	}
+	return tagValue.Bytes()
+}
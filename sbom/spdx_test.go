@@ -0,0 +1,84 @@
+package sbom
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEncodeSpdxTagValue(t *testing.T) {
+	bom := newTestSbom(t)
+	bom.Metadata.Url = "https://github.com/owner/repository"
+	tagValueBytes, encodeError := bom.EncodeSpdx(SpdxTagValue)
+	if encodeError != nil {
+		t.Fatal(encodeError)
+	}
+	content := string(tagValueBytes)
+	for _, line := range []string{
+		"SPDXVersion: SPDX-2.3",
+		"DataLicense: CC0-1.0",
+		"SPDXID: SPDXRef-DOCUMENT",
+		"DocumentName: owner/repository",
+		"DocumentNamespace: https://spdx.org/spdxdocs/owner-repository-" + strings.TrimPrefix(bom.SerialNumber, "urn:uuid:"),
+		"Creator: Tool: " + ToolName,
+		"Created: " + bom.Metadata.Timestamp.Format("2006-01-02T15:04:05Z"),
+		"PackageDownloadLocation: git+https://github.com/owner/repository",
+		"PackageName: lodash",
+		"SPDXID: SPDXRef-Package-1-lodash",
+		"PackageVersion: 4.17.21",
+		"PackageSourceInfo: <text>found in package.json, web/package.json</text>",
+		"ExternalRef: PACKAGE-MANAGER purl pkg:npm/lodash@4.17.21",
+		"Relationship: SPDXRef-DOCUMENT DESCRIBES SPDXRef-Repository",
+		"Relationship: SPDXRef-Repository DEPENDS_ON SPDXRef-Package-1-lodash",
+		"Relationship: SPDXRef-Repository DEPENDS_ON SPDXRef-Package-2-react",
+	} {
+		if !strings.Contains(content, line + "\n") {
+			t.Errorf("EncodeSpdx(tag-value) has no line %q", line)
+		}
+	}
+}
+
+func TestEncodeSpdxJson(t *testing.T) {
+	bom := newTestSbom(t)
+	jsonBytes, encodeError := bom.EncodeSpdx(SpdxJson)
+	if encodeError != nil {
+		t.Fatal(encodeError)
+	}
+	var document spdxDocument
+	if unmarshalError := json.Unmarshal(jsonBytes, &document); unmarshalError != nil {
+		t.Fatal(unmarshalError)
+	}
+	if document.SpdxVersion != "SPDX-2.3" || document.SpdxId != spdxDocumentId || !strings.HasPrefix(document.DocumentNamespace, spdxNamespaceUrl) {
+		t.Errorf("EncodeSpdx(json) header = %v %v %v", document.SpdxVersion, document.SpdxId, document.DocumentNamespace)
+	}
+	if len(document.Packages) != 3 || document.Packages[0].SpdxId != spdxRepositoryId || document.Packages[0].DownloadLocation != spdxNoAssertion {
+		t.Fatalf("EncodeSpdx(json) packages = %v", document.Packages)
+	}
+	react := document.Packages[2]
+	if react.Name != "react" || react.VersionInfo != "18.2.0" || len(react.ExternalRefs) != 1 || react.ExternalRefs[0].ReferenceLocator != "pkg:npm/react@18.2.0" {
+		t.Errorf("EncodeSpdx(json) react package = %v", react)
+	}
+	var relationships []string
+	for _, relationship := range document.Relationships {
+		relationships = append(relationships, relationship.SpdxElementId + " " + relationship.RelationshipType + " " + relationship.RelatedSpdxElement)
+	}
+	want := "SPDXRef-DOCUMENT DESCRIBES SPDXRef-Repository,SPDXRef-Repository DEPENDS_ON SPDXRef-Package-1-lodash,SPDXRef-Repository DEPENDS_ON SPDXRef-Package-2-react"
+	if strings.Join(relationships, ",") != want {
+		t.Errorf("EncodeSpdx(json) relationships = %v, want %v", relationships, want)
+	}
+}
+
+func TestEncodeSpdxWithoutRepository(t *testing.T) {
+	bom := newEmptySbom()
+	bom.Components = append(bom.Components, Component{Type: "library", Group: "org.apache.commons", Name: "commons-lang3", Version: "3.12.0", Purl: "pkg:maven/org.apache.commons/commons-lang3@3.12.0?type=jar"})
+	document := bom.toSpdx()
+	if len(document.Packages) != 1 || document.Packages[0].Name != "org.apache.commons:commons-lang3" {
+		t.Fatalf("toSpdx() packages = %v", document.Packages)
+	}
+	if len(document.Relationships) != 1 || document.Relationships[0] != (spdxRelationship{spdxDocumentId, "DESCRIBES", document.Packages[0].SpdxId}) {
+		t.Errorf("toSpdx() relationships = %v", document.Relationships)
+	}
+	if _, encodeError := bom.EncodeSpdx("yaml"); encodeError == nil {
+		t.Errorf("EncodeSpdx(yaml) returned no error")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"iq-scm-audit/sbom"
+	"iq-scm-audit/scm"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	SbomFileCycloneDxXml = "cdx-xml"
+	SbomFileCycloneDxJson = "cdx-json"
+	SbomFileSpdx = "spdx"
+	SbomFileSpdxJson = "spdx-json"
+)
+
+var sbomFileExtensions = map[string]string{
+	SbomFileCycloneDxXml: ".cdx.xml",
+	SbomFileCycloneDxJson: ".cdx.json",
+	SbomFileSpdx: ".spdx",
+	SbomFileSpdxJson: ".spdx.json",
+}
+
+func validateSbomConfiguration(configuration *AuditConfiguration) error {
+	if configuration.CycloneDxFormat != sbom.FormatXml && configuration.CycloneDxFormat != sbom.FormatJson {
+		return errors.New("unsupported CycloneDX format, use xml or json - " + configuration.CycloneDxFormat)
+	}
+	if len(configuration.CycloneDxVersion) > 0 && !sbom.IsSpecVersion(configuration.CycloneDxVersion) {
+		return errors.New("unsupported CycloneDX spec version, use one of " + strings.Join(sbom.SpecVersions, ", ") + " - " + configuration.CycloneDxVersion)
+	}
+	_, formatsError := sbomFileFormats(configuration.SbomOutputFormats)
+	return formatsError
+}
+
+func sbomFileFormats(formats string) ([]string, error) {
+	var fileFormats []string
+	for _, format := range strings.Split(formats, ",") {
+		format = strings.TrimSpace(format)
+		if len(format) == 0 {
+			continue
+		}
+		if _, supported := sbomFileExtensions[format]; !supported {
+			return nil, errors.New("unsupported SBOM output format, use cdx-xml, cdx-json, spdx or spdx-json - " + format)
+		}
+		fileFormats = append(fileFormats, format)
+	}
+	return fileFormats, nil
+}
+
+// sbomFileSpecVersion is the CycloneDX spec version of the SBOMs written, cycloneDxVersion or the newest. It does not
+// depend on the IQ Server, so the files are the same with and without sbomOnly.
+func sbomFileSpecVersion(configuration *AuditConfiguration) string {
+	if len(configuration.CycloneDxVersion) > 0 {
+		return configuration.CycloneDxVersion
+	}
+	return sbom.SpecVersions[len(sbom.SpecVersions) - 1]
+}
+
+func newResolver(configuration *AuditConfiguration) (sbom.Resolver, error) {
+	if len(configuration.VersionRegistry) == 0 {
+		return nil, nil
+	}
+	registry, registryError := sbom.LoadFileRegistry(configuration.VersionRegistry)
+	if registryError != nil {
+		return nil, registryError
+	}
+	return &sbom.RegistryResolver{Registry: registry}, nil
+}
+
+// writeRepositorySboms writes the SBOM of every repository found in source control without IQ Server, each repository
+// is reported as generated or failed.
+func writeRepositorySboms(configuration *AuditConfiguration) error {
+	validationError := validateSbomConfiguration(configuration)
+	if validationError != nil {
+		return validationError
+	}
+	if len(configuration.SbomOutputDir) == 0 {
+		return errors.New("sbomOnly needs sbomOutputDir")
+	}
+	resolver, resolverError := newResolver(configuration)
+	if resolverError != nil {
+		return resolverError
+	}
+	scmClient, _, scmQuery, scmError := newScmClient(configuration)
+	if scmError != nil {
+		return scmError
+	}
+	log.Println("Getting " + scmClient.Provider() + " Repositories")
+	repositories, repositoriesError := scmClient.GetRepositories(scmQuery)
+	if repositoriesError != nil {
+		return repositoriesError
+	}
+
+	var report = NewReport()
+	var failures = new(Failures)
+	var jobs []*RepositoryJob
+	for _, repository := range repositories {
+		jobs = append(jobs, &RepositoryJob{Repository: repository, ReportRow: report.AddRow(repository.NameWithOwner)})
+	}
+	runConcurrently(len(jobs), configuration.Concurrency, func(index int) {
+		job := jobs[index]
+		if job.Repository.Error != nil {
+			job.Error = job.Repository.Error
+			return
+		}
+		bom, sbomError := newRepositorySbom(job.Repository, resolver)
+		if sbomError != nil {
+			job.Error = sbomError
+			return
+		}
+		job.Error = writeSbomFiles(configuration, job.Repository, bom)
+	})
+	for _, job := range jobs {
+		if job.Error != nil {
+			failures.Add(job.ReportRow, job.Error)
+			continue
+		}
+		job.ReportRow.Status = StatusGenerated
+	}
+
+	if len(configuration.ReportFile) > 0 {
+		reportError := report.Write(configuration.ReportFile, configuration.ReportFormat)
+		if reportError != nil {
+			return reportError
+		}
+	}
+	failures.Print()
+	if len(failures.Rows) > 0 {
+		return fmt.Errorf("%v of %v repositories failed", len(failures.Rows), len(report.Rows))
+	}
+	return nil
+}
+
+// newRepositorySbom builds the SBOM of the repository dependencies and logs those left out of it.
+func newRepositorySbom(repository scm.Repository, resolver sbom.Resolver) (*sbom.Sbom, error) {
+	bom, sbomError := sbom.NewSbom(repository, resolver)
+	if sbomError != nil {
+		return nil, sbomError
+	}
+	if bom.SkippedCount() > 0 {
+		log.Println(fmt.Sprintf("Skipped %v dependencies of unsupported ecosystems or without a package url %v - %v", bom.SkippedCount(), bom.Skipped, repository.NameWithOwner))
+	}
+	if len(bom.Invalid) > 0 {
+		log.Println(fmt.Sprintf("Skipped %v dependencies without a package url %v - %v", len(bom.Invalid), bom.Invalid, repository.NameWithOwner))
+	}
+	if len(bom.Unresolved) > 0 {
+		log.Println(fmt.Sprintf("Skipped %v dependencies with only a version range %v - %v", len(bom.Unresolved), bom.Unresolved, repository.NameWithOwner))
+	}
+	return bom, nil
+}
+
+// writeSbomFiles writes the SBOM of the repository to <sbomOutputDir>/<owner>/<repository> with the extension of each
+// of the sbomOutputFormats.
+func writeSbomFiles(configuration *AuditConfiguration, repository scm.Repository, bom *sbom.Sbom) error {
+	formats, formatsError := sbomFileFormats(configuration.SbomOutputFormats)
+	if formatsError != nil {
+		return formatsError
+	}
+	for _, format := range formats {
+		var content []byte
+		var encodeError error
+		switch format {
+		case SbomFileCycloneDxXml:
+			content, encodeError = bom.Encode(sbomFileSpecVersion(configuration), sbom.FormatXml)
+		case SbomFileCycloneDxJson:
+			content, encodeError = bom.Encode(sbomFileSpecVersion(configuration), sbom.FormatJson)
+		case SbomFileSpdx:
+			content, encodeError = bom.EncodeSpdx(sbom.SpdxTagValue)
+		case SbomFileSpdxJson:
+			content, encodeError = bom.EncodeSpdx(sbom.SpdxJson)
+		}
+		if encodeError != nil {
+			return encodeError
+		}
+		writeError := writeSbomFile(sbomFilePath(configuration.SbomOutputDir, repository, sbomFileExtensions[format]), content)
+		if writeError != nil {
+			return writeError
+		}
+	}
+	return nil
+}
+
+func sbomFilePath(directory string, repository scm.Repository, extension string) string {
+	return filepath.Join(directory, filepath.FromSlash(repository.NameWithOwner) + extension)
+}
+
+func writeSbomFile(path string, content []byte) error {
+	mkdirError := os.MkdirAll(filepath.Dir(path), 0755)
+	if mkdirError != nil {
+		return mkdirError
+	}
+	writeError := ioutil.WriteFile(path, content, 0644)
+	if writeError != nil {
+		return writeError
+	}
+	log.Println("Wrote SBOM - " + path)
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"iq-scm-audit/sbom"
+	"strings"
+	"testing"
+)
+
+func TestSbomFileFormats(t *testing.T) {
+	tests := []struct {
+		formats string
+		parsed string
+		valid bool
+	}{
+		{"cdx-xml", "cdx-xml", true},
+		{" cdx-json , spdx-json,", "cdx-json,spdx-json", true},
+		{"cdx-xml,cdx-json,spdx,spdx-json", "cdx-xml,cdx-json,spdx,spdx-json", true},
+		{"", "", true},
+		{"cdx-xml,swid", "", false},
+	}
+	for _, test := range tests {
+		formats, formatsError := sbomFileFormats(test.formats)
+		if (formatsError == nil) != test.valid {
+			t.Errorf("sbomFileFormats(%q) returned %v, want valid %v", test.formats, formatsError, test.valid)
+			continue
+		}
+		if strings.Join(formats, ",") != test.parsed {
+			t.Errorf("sbomFileFormats(%q) = %v, want %v", test.formats, formats, test.parsed)
+		}
+	}
+}
+
+func TestValidateSbomConfiguration(t *testing.T) {
+	tests := []struct {
+		cycloneDxVersion string
+		cycloneDxFormat string
+		sbomOutputFormats string
+		valid bool
+	}{
+		{"", sbom.FormatXml, "cdx-xml,spdx-json", true},
+		{"1.4", sbom.FormatJson, "cdx-json", true},
+		{"1.6", sbom.FormatXml, "cdx-xml", false},
+		{"", "yaml", "cdx-xml", false},
+		{"", sbom.FormatXml, "cdx-xml,swid", false},
+	}
+	for _, test := range tests {
+		configuration := &AuditConfiguration{
+			CycloneDxVersion: test.cycloneDxVersion,
+			CycloneDxFormat: test.cycloneDxFormat,
+			SbomOutputFormats: test.sbomOutputFormats,
+		}
+		validationError := validateSbomConfiguration(configuration)
+		if (validationError == nil) != test.valid {
+			t.Errorf("validateSbomConfiguration(%q, %q, %q) = %v, want valid %v", test.cycloneDxVersion, test.cycloneDxFormat, test.sbomOutputFormats, validationError, test.valid)
+		}
+	}
+}
+
+func TestSbomFileSpecVersion(t *testing.T) {
+	if version := sbomFileSpecVersion(&AuditConfiguration{CycloneDxVersion: "1.3"}); version != "1.3" {
+		t.Errorf("sbomFileSpecVersion(1.3) = %q, want 1.3", version)
+	}
+	if version := sbomFileSpecVersion(&AuditConfiguration{}); version != sbom.SpecVersions[len(sbom.SpecVersions) - 1] {
+		t.Errorf("sbomFileSpecVersion() = %q, want the newest", version)
+	}
+}
@@ -0,0 +1,87 @@
+package scm
+
+import "errors"
+
+// ErrIssueSkipped is returned by CreateIssue when the provider has nowhere to file the issue for the repository, such
+// as Bitbucket Server without Jira or an open pull request. Nothing was filed, so it is tried again on the next audit.
+var ErrIssueSkipped = errors.New("nowhere to file the issue")
+
+type Client interface {
+	Provider() string
+	ServerUrl() string
+	GetRepositories(query string) ([]Repository, error)
+	CreateIssue(repositoryNameWithOwner string, title string, markdown string) (string, error)
+	DownloadRelease(url string) ([]byte, error)
+}
+
+type PullRequestClient interface {
+	CreatePullRequest(repositoryNameWithOwner string, branch string, path string, content string, title string, markdown string) (string, error)
+}
+
+// TeamClient is implemented by providers that know which team owns a repository.
+type TeamClient interface {
+	// OwningTeam returns an empty name when no team owns the repository.
+	OwningTeam(repositoryNameWithOwner string) (string, error)
+}
+
+// MaintainerClient is implemented by providers that can list who administers or maintains a repository.
+type MaintainerClient interface {
+	Maintainers(repositoryNameWithOwner string) ([]Member, error)
+}
+
+type Repository struct {
+	Name string
+	// Set when Name is not a valid IQ Application public id, ApplicationPublicId falls back to Name
+	PublicId string
+	NameWithOwner string
+	Url string
+	SshUrl string
+	Topics []string
+	Language string
+	// public, private or internal
+	Visibility string
+	Archived bool
+	Manifests []Manifest
+	ReleaseAssets []Asset
+	PackageFiles []Asset
+	// Set when the repository was found but its details could not be read, the audit records it as a failure
+	Error error `json:"-"`
+}
+
+// Member is a source control user, Email is empty when the user does not make it public.
+type Member struct {
+	Login string
+	Email string
+}
+
+type Manifest struct {
+	Filename string
+	Dependencies []Dependency
+}
+
+type Dependency struct {
+	PackageManager string
+	PackageName    string
+	Requirements   string
+}
+
+type Asset struct {
+	Name string
+	Url string
+}
+
+// ApplicationPublicId is the public id of the IQ Application the repository is audited in.
+func (repository *Repository) ApplicationPublicId() string {
+	if len(repository.PublicId) > 0 {
+		return repository.PublicId
+	}
+	return repository.Name
+}
+
+func (repository *Repository) Dependencies() []Dependency {
+	var dependencies []Dependency
+	for _, manifest := range repository.Manifests {
+		dependencies = append(dependencies, manifest.Dependencies...)
+	}
+	return dependencies
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"iq-scm-audit/scm"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// State records the repositories found and how far each one got, so an interrupted audit can be resumed. Fingerprints
+// of what was scanned are kept across audits for incremental audits. The repositories, with their dependency graphs,
+// are written once to a file of their own, so saving progress only writes the progress and fingerprints.
+type State struct {
+	Provider string `json:"provider"`
+	Query string `json:"query"`
+	Repositories []scm.Repository `json:"-"`
+	// Repositories that could not be read are not final, a resumed audit searches for them again
+	FailedRepositories []string `json:"-"`
+	Progress map[string]*RepositoryProgress `json:"progress"`
+	Fingerprints map[string]*Fingerprint `json:"fingerprints"`
+	path string
+	lock sync.Mutex
+	// Writes are serialized by saveLock outside of lock, so workers do not wait on the disk to record progress
+	saveLock sync.Mutex
+	generation int
+	savedGeneration int
+}
+
+type stateRepositories struct {
+	Repositories []scm.Repository `json:"repositories"`
+	FailedRepositories []string `json:"failedRepositories,omitempty"`
+}
+
+// RepositoryProgress holds the stages a repository completed along with their results, a resumed audit reuses the
+// results instead of repeating the stage.
+type RepositoryProgress struct {
+	ApplicationCreated bool `json:"applicationCreated"`
+	ApplicationId string `json:"applicationId,omitempty"`
+	ApplicationPublicId string `json:"applicationPublicId,omitempty"`
+	ApplicationStatus string `json:"applicationStatus,omitempty"`
+	ScmSet bool `json:"scmSet"`
+	RoleMembersAdded bool `json:"roleMembersAdded"`
+	CategoriesApplied bool `json:"categoriesApplied"`
+	SbomScanned bool `json:"sbomScanned"`
+	SbomPolicyAction string `json:"sbomPolicyAction,omitempty"`
+	AuditReportUrl string `json:"auditReportUrl,omitempty"`
+	ReleaseEvaluated bool `json:"releaseEvaluated"`
+	ReleaseReportUrl string `json:"releaseReportUrl,omitempty"`
+	PackageEvaluated bool `json:"packageEvaluated"`
+	PackageReportUrl string `json:"packageReportUrl,omitempty"`
+	IssueFiled bool `json:"issueFiled"`
+	IssueUrl string `json:"issueUrl,omitempty"`
+}
+
+// NewState starts an empty state that is saved to path, an empty path keeps the state in memory only.
+func NewState(path string) *State {
+	state := new(State)
+	state.path = path
+	state.Progress = make(map[string]*RepositoryProgress)
+	state.Fingerprints = make(map[string]*Fingerprint)
+	return state
+}
+
+// LoadState reads the state of a previous audit, a missing file starts an empty state.
+func LoadState(path string) (*State, error) {
+	state := NewState(path)
+	stateBytes, readError := ioutil.ReadFile(path)
+	if os.IsNotExist(readError) {
+		return state, nil
+	}
+	if readError != nil {
+		return nil, readError
+	}
+	unmarshalError := json.Unmarshal(stateBytes, state)
+	if unmarshalError != nil {
+		return nil, errors.New("unable to read state file " + path + " - " + unmarshalError.Error())
+	}
+	if state.Progress == nil {
+		state.Progress = make(map[string]*RepositoryProgress)
+	}
+	if state.Fingerprints == nil {
+		state.Fingerprints = make(map[string]*Fingerprint)
+	}
+	if len(state.Provider) == 0 {
+		return state, nil
+	}
+	// Without the repositories file the repositories are searched for again
+	repositoriesBytes, readError := ioutil.ReadFile(repositoriesPath(path))
+	if os.IsNotExist(readError) {
+		return state, nil
+	}
+	if readError != nil {
+		return nil, readError
+	}
+	var repositories stateRepositories
+	unmarshalError = json.Unmarshal(repositoriesBytes, &repositories)
+	if unmarshalError != nil {
+		return nil, errors.New("unable to read state file " + repositoriesPath(path) + " - " + unmarshalError.Error())
+	}
+	state.Repositories = repositories.Repositories
+	state.FailedRepositories = repositories.FailedRepositories
+	return state, nil
+}
+
+// Reset forgets the repositories and progress of the previous audit to start a new one, fingerprints are kept.
+func (state *State) Reset() {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	state.Provider = ""
+	state.Query = ""
+	state.Repositories = nil
+	state.FailedRepositories = nil
+	state.Progress = make(map[string]*RepositoryProgress)
+}
+
+// HasRepositories reports whether the repositories of the same provider and query were already found and all of them
+// could be read.
+func (state *State) HasRepositories(provider string, query string) bool {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	return state.Provider == provider && state.Query == query && state.Repositories != nil && len(state.FailedRepositories) == 0
+}
+
+// SetRepositories records the repositories found, they are written before the provider and query that refer to them.
+func (state *State) SetRepositories(provider string, query string, repositories []scm.Repository) error {
+	state.lock.Lock()
+	state.Provider = provider
+	state.Query = query
+	state.Repositories = repositories
+	if state.Repositories == nil {
+		state.Repositories = []scm.Repository{}
+	}
+	state.FailedRepositories = nil
+	for _, repository := range repositories {
+		if repository.Error != nil {
+			state.FailedRepositories = append(state.FailedRepositories, repository.NameWithOwner)
+		}
+	}
+	if len(state.path) > 0 {
+		repositoriesBytes, marshalError := json.Marshal(stateRepositories{state.Repositories, state.FailedRepositories})
+		if marshalError != nil {
+			state.lock.Unlock()
+			return marshalError
+		}
+		writeError := writeFileAtomically(repositoriesPath(state.path), repositoriesBytes)
+		if writeError != nil {
+			state.lock.Unlock()
+			return writeError
+		}
+	}
+	stateBytes, generation, marshalError := state.snapshot()
+	state.lock.Unlock()
+	if marshalError != nil {
+		return marshalError
+	}
+	return state.save(stateBytes, generation)
+}
+
+// RepositoryProgress returns a copy of the progress recorded for the repository.
+func (state *State) RepositoryProgress(nameWithOwner string) RepositoryProgress {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	progress, found := state.Progress[nameWithOwner]
+	if !found {
+		return RepositoryProgress{}
+	}
+	return *progress
+}
+
+// Update records a completed stage and saves the state straight away, so it survives the process being killed.
+func (state *State) Update(nameWithOwner string, update func(progress *RepositoryProgress)) error {
+	state.lock.Lock()
+	progress, found := state.Progress[nameWithOwner]
+	if !found {
+		progress = new(RepositoryProgress)
+		state.Progress[nameWithOwner] = progress
+	}
+	update(progress)
+	stateBytes, generation, marshalError := state.snapshot()
+	state.lock.Unlock()
+	if marshalError != nil {
+		return marshalError
+	}
+	return state.save(stateBytes, generation)
+}
+
+// Fingerprint returns a copy of the fingerprint recorded for the repository by the last audit that scanned it.
+func (state *State) Fingerprint(nameWithOwner string) Fingerprint {
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	fingerprint, found := state.Fingerprints[nameWithOwner]
+	if !found {
+		return Fingerprint{}
+	}
+	return *fingerprint
+}
+
+func (state *State) UpdateFingerprint(nameWithOwner string, update func(fingerprint *Fingerprint)) error {
+	state.lock.Lock()
+	fingerprint, found := state.Fingerprints[nameWithOwner]
+	if !found {
+		fingerprint = new(Fingerprint)
+		state.Fingerprints[nameWithOwner] = fingerprint
+	}
+	update(fingerprint)
+	stateBytes, generation, marshalError := state.snapshot()
+	state.lock.Unlock()
+	if marshalError != nil {
+		return marshalError
+	}
+	return state.save(stateBytes, generation)
+}
+
+// snapshot encodes the state while lock is held, each snapshot is a newer generation than the last.
+func (state *State) snapshot() ([]byte, int, error) {
+	if len(state.path) == 0 {
+		return nil, 0, nil
+	}
+	state.generation++
+	stateBytes, marshalError := json.MarshalIndent(state, "", "  ")
+	return stateBytes, state.generation, marshalError
+}
+
+// save writes a snapshot unless a newer one was already written, a snapshot that lost the race is already part of it.
+func (state *State) save(stateBytes []byte, generation int) error {
+	if len(state.path) == 0 {
+		return nil
+	}
+	state.saveLock.Lock()
+	defer state.saveLock.Unlock()
+	if generation <= state.savedGeneration {
+		return nil
+	}
+	writeError := writeFileAtomically(state.path, stateBytes)
+	if writeError != nil {
+		return writeError
+	}
+	state.savedGeneration = generation
+	return nil
+}
+
+// repositoriesPath is the file the repositories are kept in next to the state, e.g. state.repositories.json.
+func repositoriesPath(path string) string {
+	extension := filepath.Ext(path)
+	return strings.TrimSuffix(path, extension) + ".repositories" + extension
+}
+
+// Written to a temporary file and renamed over the file, so a crash mid write leaves the previous file intact.
+func writeFileAtomically(path string, content []byte) error {
+	writeError := ioutil.WriteFile(path + ".tmp", content, 0600)
+	if writeError != nil {
+		return writeError
+	}
+	return os.Rename(path + ".tmp", path)
+}
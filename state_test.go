@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"iq-scm-audit/scm"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStateKeepsRepositoriesInTheirOwnFile(t *testing.T) {
+	directory, directoryError := ioutil.TempDir("", "state")
+	if directoryError != nil {
+		t.Fatal(directoryError)
+	}
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "state.json")
+
+	state := NewState(path)
+	repositories := []scm.Repository{
+		{NameWithOwner: "owner/one", Manifests: []scm.Manifest{{Filename: "package.json", Dependencies: []scm.Dependency{{PackageManager: "NPM", PackageName: "lodash", Requirements: "4.17.21"}}}}},
+		{NameWithOwner: "owner/two", Error: errors.New("dependency graph unavailable")},
+	}
+	if setError := state.SetRepositories("github", "org:owner", repositories); setError != nil {
+		t.Fatal(setError)
+	}
+	if updateError := state.Update("owner/one", func(progress *RepositoryProgress) { progress.SbomScanned = true }); updateError != nil {
+		t.Fatal(updateError)
+	}
+	if updateError := state.UpdateFingerprint("owner/one", func(fingerprint *Fingerprint) { fingerprint.Dependencies = "abc" }); updateError != nil {
+		t.Fatal(updateError)
+	}
+
+	stateBytes, readError := ioutil.ReadFile(path)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+	if strings.Contains(string(stateBytes), "lodash") {
+		t.Error("state file contains the dependency graph")
+	}
+	if _, statError := os.Stat(filepath.Join(directory, "state.repositories.json")); statError != nil {
+		t.Error(statError)
+	}
+
+	loaded, loadError := LoadState(path)
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+	if len(loaded.Repositories) != 2 {
+		t.Fatalf("LoadState found %v repositories, want 2", len(loaded.Repositories))
+	}
+	if loaded.Repositories[1].Error != nil || len(loaded.FailedRepositories) != 1 || loaded.FailedRepositories[0] != "owner/two" {
+		t.Errorf("LoadState repository error, failed repositories = %v, %v, want owner/two to be searched again", loaded.Repositories[1].Error, loaded.FailedRepositories)
+	}
+	if loaded.HasRepositories("github", "org:owner") {
+		t.Error("HasRepositories() = true with a repository that could not be read")
+	}
+	if !loaded.RepositoryProgress("owner/one").SbomScanned || loaded.Fingerprint("owner/one").Dependencies != "abc" {
+		t.Error("LoadState lost the progress or fingerprint")
+	}
+
+	if setError := loaded.SetRepositories("github", "org:owner", repositories[:1]); setError != nil {
+		t.Fatal(setError)
+	}
+	reloaded, loadError := LoadState(path)
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+	if !reloaded.HasRepositories("github", "org:owner") || !reloaded.RepositoryProgress("owner/one").SbomScanned {
+		t.Error("LoadState did not reuse the repositories once all of them were read")
+	}
+}
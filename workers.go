@@ -0,0 +1,38 @@
+package main
+
+import (
+	"iq-scm-audit/scm"
+	"sync"
+)
+
+type RepositoryJob struct {
+	Repository scm.Repository
+	ReportRow *ReportRow
+	Plan *Plan
+	IssueData *IssueData
+	Error error
+}
+
+// runConcurrently calls work for every index from 0 to count on at most concurrency goroutines and returns once all
+// have finished.
+func runConcurrently(count int, concurrency int, work func(index int)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	indexes := make(chan int)
+	var waitGroup sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < count; worker++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for index := range indexes {
+				work(index)
+			}
+		}()
+	}
+	for index := 0; index < count; index++ {
+		indexes <- index
+	}
+	close(indexes)
+	waitGroup.Wait()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"iq-scm-audit/scm"
+	"path"
+	"strings"
+	"text/template"
+)
+
+const workflowPath = ".github/workflows/nexus-iq.yml"
+const pullRequestBranch = "nexus-iq-policy-evaluation"
+const pullRequestHeader = "This pull request adds a GitHub Actions workflow that evaluates every build against Nexus IQ policy. " +
+	"Add `NEXUS_IQ_USERNAME` and `NEXUS_IQ_PASSWORD` repository secrets before merging.\n\n"
+
+type BuildSystem struct {
+	Name string
+	Setup string
+	BuildCommand string
+	ScanTarget string
+}
+
+type WorkflowData struct {
+	IqServerUrl string
+	Repository string
+	BuildSystem BuildSystem
+}
+
+var buildSystems = []struct {
+	Matches func(filename string) bool
+	BuildSystem BuildSystem
+}{
+	{
+		Matches: func(filename string) bool { return filename == "pom.xml" },
+		BuildSystem: BuildSystem{
+			Name: "Maven",
+			Setup: "java",
+			BuildCommand: "mvn -B package -DskipTests",
+			ScanTarget: "target/",
+		},
+	},
+	{
+		Matches: func(filename string) bool { return filename == "build.gradle" || filename == "build.gradle.kts" },
+		BuildSystem: BuildSystem{
+			Name: "Gradle",
+			Setup: "java",
+			BuildCommand: "./gradlew build -x test",
+			ScanTarget: "build/libs/",
+		},
+	},
+	{
+		Matches: func(filename string) bool { return filename == "package.json" || filename == "package-lock.json" },
+		BuildSystem: BuildSystem{
+			Name: "npm",
+			Setup: "node",
+			BuildCommand: "npm ci",
+			ScanTarget: ".",
+		},
+	},
+	{
+		Matches: func(filename string) bool { return strings.HasSuffix(filename, ".csproj") || filename == "packages.config" },
+		BuildSystem: BuildSystem{
+			Name: ".NET",
+			Setup: "dotnet",
+			BuildCommand: "dotnet publish -c Release -o out",
+			ScanTarget: "out/",
+		},
+	},
+}
+
+// Manifests closest to the repository root decide the build system, e.g. a root pom.xml wins over a nested package.json.
+func detectBuildSystem(manifests []scm.Manifest) *BuildSystem {
+	var detected *BuildSystem
+	detectedDepth := -1
+	for _, manifest := range manifests {
+		filename := path.Base(manifest.Filename)
+		depth := strings.Count(strings.Trim(manifest.Filename, "/"), "/")
+		for index := range buildSystems {
+			if buildSystems[index].Matches(filename) && (detected == nil || depth < detectedDepth) {
+				detected = &buildSystems[index].BuildSystem
+				detectedDepth = depth
+			}
+		}
+	}
+	return detected
+}
+
+func renderWorkflow(workflowTemplate *template.Template, iqServerUrl string, applicationPublicId string, buildSystem *BuildSystem) (string, error) {
+	workflowData := new(WorkflowData)
+	workflowData.IqServerUrl = iqServerUrl
+	workflowData.Repository = applicationPublicId
+	workflowData.BuildSystem = *buildSystem
+	var workflowBytes bytes.Buffer
+	templateError := workflowTemplate.Execute(&workflowBytes, workflowData)
+	return workflowBytes.String(), templateError
+}
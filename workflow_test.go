@@ -0,0 +1,53 @@
+package main
+
+import (
+	"iq-scm-audit/scm"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestDetectBuildSystem(t *testing.T) {
+	tests := []struct {
+		manifests []string
+		buildSystem string
+	}{
+		{[]string{"pom.xml"}, "Maven"},
+		{[]string{"app/build.gradle.kts"}, "Gradle"},
+		{[]string{"web/package.json", "pom.xml"}, "Maven"},
+		{[]string{"service/pom.xml", "package-lock.json"}, "npm"},
+		{[]string{"src/App/App.csproj"}, ".NET"},
+		{[]string{"requirements.txt"}, ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		var manifests []scm.Manifest
+		for _, filename := range test.manifests {
+			manifests = append(manifests, scm.Manifest{Filename: filename})
+		}
+		buildSystem := detectBuildSystem(manifests)
+		var name string
+		if buildSystem != nil {
+			name = buildSystem.Name
+		}
+		if name != test.buildSystem {
+			t.Errorf("detectBuildSystem(%v) = %q, want %q", test.manifests, name, test.buildSystem)
+		}
+	}
+}
+
+func TestRenderWorkflow(t *testing.T) {
+	workflowTemplate, parseError := template.New("github-workflow.yml").Delims("[[", "]]").ParseFiles("github-workflow.yml")
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+	workflow, renderError := renderWorkflow(workflowTemplate, "https://iq.example.com", "owner-repository", detectBuildSystem([]scm.Manifest{{Filename: "pom.xml"}}))
+	if renderError != nil {
+		t.Fatal(renderError)
+	}
+	for _, expected := range []string{"https://iq.example.com", "owner-repository", "mvn -B package -DskipTests", "target/"} {
+		if !strings.Contains(workflow, expected) {
+			t.Errorf("renderWorkflow() has no %q", expected)
+		}
+	}
+}